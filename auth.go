@@ -0,0 +1,438 @@
+package sip
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Identity identifies the caller behind an authenticated request or
+// connection. It is surfaced to the recorder and named sessions so logs
+// and asciicast output can be attributed to a user.
+type Identity struct {
+	Subject string
+	Roles   []string
+}
+
+// Authenticator gates access to sip's HTTP and WebSocket/WebTransport
+// routes. AuthenticateHTTP verifies a request and returns the caller's
+// Identity; Authorize then decides whether that Identity may perform
+// action (e.g. "connect", "drive", "watch").
+type Authenticator interface {
+	AuthenticateHTTP(r *http.Request) (Identity, error)
+	Authorize(id Identity, action string) bool
+}
+
+// authenticateHTTP runs Config.Authenticator (if set) against r, writing a
+// 401 with a WWW-Authenticate header on failure. It reports whether the
+// caller may proceed.
+func (s *httpServer) authenticateHTTP(w http.ResponseWriter, r *http.Request) (Identity, bool) {
+	if s.config.Authenticator == nil {
+		return Identity{}, true
+	}
+
+	id, err := s.config.Authenticator.AuthenticateHTTP(r)
+	if err != nil {
+		s.metrics.rejected("auth")
+		w.Header().Set("WWW-Authenticate", `Bearer realm="sip"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return Identity{}, false
+	}
+	return id, true
+}
+
+// authenticateWebTransport gates handleWebTransport. Browsers cannot attach
+// an Authorization header to the WebTransport CONNECT request, so the
+// caller is expected to present a ticket minted by POST /wt-ticket as a
+// "ticket" query parameter instead; non-browser clients may still send a
+// normal Authorization header. It writes a 401 and reports false on
+// failure.
+func (s *httpServer) authenticateWebTransport(w http.ResponseWriter, r *http.Request) (Identity, bool) {
+	if s.config.Authenticator == nil {
+		return Identity{}, true
+	}
+
+	if ticket := r.URL.Query().Get("ticket"); ticket != "" {
+		id, err := s.verifyWTTicket(ticket)
+		if err != nil {
+			s.metrics.rejected("auth")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return Identity{}, false
+		}
+		return id, true
+	}
+
+	return s.authenticateHTTP(w, r)
+}
+
+// BasicAuthenticator authenticates with HTTP Basic auth against a static
+// username/password table. Authorize always allows an authenticated
+// Identity.
+type BasicAuthenticator struct {
+	// Users maps username to password.
+	Users map[string]string
+}
+
+func (a *BasicAuthenticator) AuthenticateHTTP(r *http.Request) (Identity, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return Identity{}, errors.New("missing basic auth credentials")
+	}
+	want, exists := a.Users[user]
+	if !exists || subtle.ConstantTimeCompare([]byte(pass), []byte(want)) != 1 {
+		return Identity{}, errors.New("invalid credentials")
+	}
+	return Identity{Subject: user}, nil
+}
+
+func (a *BasicAuthenticator) Authorize(_ Identity, _ string) bool {
+	return true
+}
+
+// BearerAuthenticator authenticates with a static list of bearer tokens,
+// each bound to an Identity.
+type BearerAuthenticator struct {
+	// Tokens maps a bearer token to the Identity it authenticates as.
+	Tokens map[string]Identity
+}
+
+func (a *BearerAuthenticator) AuthenticateHTTP(r *http.Request) (Identity, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return Identity{}, errors.New("missing bearer token")
+	}
+	id, ok := a.Tokens[token]
+	if !ok {
+		return Identity{}, errors.New("invalid bearer token")
+	}
+	return id, nil
+}
+
+func (a *BearerAuthenticator) Authorize(id Identity, action string) bool {
+	return roleAllows(id.Roles, action)
+}
+
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if strings.HasPrefix(h, prefix) {
+		return strings.TrimPrefix(h, prefix)
+	}
+	return r.URL.Query().Get("access_token")
+}
+
+// roleAllows reports whether roles contains action or the "admin" role.
+// It's the authorization rule shared by the built-in authenticators; a
+// custom Authenticator is free to use a different policy entirely.
+func roleAllows(roles []string, action string) bool {
+	for _, role := range roles {
+		if role == action || role == "admin" {
+			return true
+		}
+	}
+	return false
+}
+
+// JWTAuthenticator verifies bearer tokens as JWTs, either with a shared
+// HMAC secret (HS256) or, when JWKSURL is set, RSA keys fetched from a
+// JWKS endpoint and matched by "kid" (RS256). Roles are read from the
+// claim named RolesClaim (default "roles").
+type JWTAuthenticator struct {
+	// HMACSecret verifies HS256 tokens. Ignored if JWKSURL is set.
+	HMACSecret []byte
+
+	// JWKSURL, if set, verifies RS256 tokens against keys fetched from
+	// this JWKS endpoint (cached in-process, keyed by "kid").
+	JWKSURL string
+
+	// RolesClaim is the JWT claim holding the caller's roles (default
+	// "roles").
+	RolesClaim string
+
+	jwksMu      sync.Mutex
+	jwks        map[string]*rsa.PublicKey
+	jwksFetched time.Time
+}
+
+// jwksRefetchCooldown bounds how often resolveKey will refetch the JWKS
+// document on a cache miss, so a flood of tokens bearing unknown "kid"
+// values can't force unlimited, lock-serialized fetches against the JWKS
+// endpoint.
+const jwksRefetchCooldown = 30 * time.Second
+
+func (a *JWTAuthenticator) AuthenticateHTTP(r *http.Request) (Identity, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return Identity{}, errors.New("missing bearer token")
+	}
+	return a.verify(token)
+}
+
+func (a *JWTAuthenticator) Authorize(id Identity, action string) bool {
+	return roleAllows(id.Roles, action)
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (a *JWTAuthenticator) verify(token string) (Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Identity{}, errors.New("malformed JWT")
+	}
+	signingInput := parts[0] + "." + parts[1]
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid JWT header: %w", err)
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return Identity{}, fmt.Errorf("invalid JWT header: %w", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid JWT signature encoding: %w", err)
+	}
+
+	switch header.Alg {
+	case "HS256":
+		if a.JWKSURL != "" {
+			return Identity{}, errors.New("HS256 token rejected: authenticator is configured for RS256/JWKS")
+		}
+		mac := hmac.New(sha256.New, a.HMACSecret)
+		mac.Write([]byte(signingInput))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return Identity{}, errors.New("invalid JWT signature")
+		}
+	case "RS256":
+		key, err := a.resolveKey(header.Kid)
+		if err != nil {
+			return Identity{}, err
+		}
+		sum := sha256.Sum256([]byte(signingInput))
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig); err != nil {
+			return Identity{}, fmt.Errorf("invalid JWT signature: %w", err)
+		}
+	default:
+		return Identity{}, fmt.Errorf("unsupported JWT algorithm %q", header.Alg)
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid JWT payload: %w", err)
+	}
+
+	rolesClaim := a.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payloadJSON, &claims); err != nil {
+		return Identity{}, fmt.Errorf("invalid JWT payload: %w", err)
+	}
+
+	if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() > int64(exp) {
+		return Identity{}, errors.New("JWT expired")
+	}
+
+	id := Identity{}
+	if sub, ok := claims["sub"].(string); ok {
+		id.Subject = sub
+	}
+	if raw, ok := claims[rolesClaim].([]any); ok {
+		for _, r := range raw {
+			if s, ok := r.(string); ok {
+				id.Roles = append(id.Roles, s)
+			}
+		}
+	}
+	return id, nil
+}
+
+// jwk is the subset of RFC 7517 fields needed for an RSA verification key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// resolveKey returns the RSA public key for kid, fetching and caching the
+// JWKS document on first use. If kid isn't in the cached document, it
+// refetches once before failing, since the signer may have rotated its
+// keys since our last fetch; within jwksRefetchCooldown of the last fetch
+// it fails without refetching.
+func (a *JWTAuthenticator) resolveKey(kid string) (*rsa.PublicKey, error) {
+	a.jwksMu.Lock()
+	defer a.jwksMu.Unlock()
+
+	if a.jwks == nil {
+		if err := a.fetchJWKSLocked(); err != nil {
+			return nil, err
+		}
+	}
+	if key, ok := a.jwks[kid]; ok {
+		return key, nil
+	}
+
+	if time.Since(a.jwksFetched) < jwksRefetchCooldown {
+		return nil, fmt.Errorf("no JWKS key for kid %q", kid)
+	}
+	if err := a.fetchJWKSLocked(); err != nil {
+		return nil, err
+	}
+	key, ok := a.jwks[kid]
+	if !ok {
+		return nil, fmt.Errorf("no JWKS key for kid %q", kid)
+	}
+	return key, nil
+}
+
+// fetchJWKSLocked fetches and caches the JWKS document. Callers must hold
+// jwksMu.
+func (a *JWTAuthenticator) fetchJWKSLocked() error {
+	resp, err := http.Get(a.JWKSURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var doc jwks
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		nBytes, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(nBytes),
+			E: int(new(big.Int).SetBytes(eBytes).Int64()),
+		}
+	}
+	a.jwks = keys
+	a.jwksFetched = time.Now()
+	return nil
+}
+
+// wtTicketTTL bounds how long a WebTransport ticket minted by POST
+// /wt-ticket remains valid. WebTransport's CONNECT request can't carry an
+// Authorization header, so the short-lived ticket travels in the query
+// string instead.
+const wtTicketTTL = 30 * time.Second
+
+type wtTicketClaims struct {
+	Subject string   `json:"sub"`
+	Roles   []string `json:"roles,omitempty"`
+	Expiry  int64    `json:"exp"`
+}
+
+// mintWTTicket signs an HMAC-authenticated, short-lived ticket for id
+// using the server's per-process ticket secret.
+func (s *httpServer) mintWTTicket(id Identity) (string, error) {
+	payload, err := json.Marshal(wtTicketClaims{
+		Subject: id.Subject,
+		Roles:   id.Roles,
+		Expiry:  time.Now().Add(wtTicketTTL).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, s.ticketSecret())
+	mac.Write([]byte(encoded))
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	return encoded + "." + sig, nil
+}
+
+// verifyWTTicket checks a ticket minted by mintWTTicket.
+func (s *httpServer) verifyWTTicket(ticket string) (Identity, error) {
+	parts := strings.SplitN(ticket, ".", 2)
+	if len(parts) != 2 {
+		return Identity{}, errors.New("malformed ticket")
+	}
+
+	mac := hmac.New(sha256.New, s.ticketSecret())
+	mac.Write([]byte(parts[0]))
+	expected := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(parts[1])) {
+		return Identity{}, errors.New("invalid ticket signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return Identity{}, fmt.Errorf("invalid ticket payload: %w", err)
+	}
+	var claims wtTicketClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Identity{}, fmt.Errorf("invalid ticket payload: %w", err)
+	}
+	if time.Now().Unix() > claims.Expiry {
+		return Identity{}, errors.New("ticket expired")
+	}
+	return Identity{Subject: claims.Subject, Roles: claims.Roles}, nil
+}
+
+// handleWTTicket mints a short-lived WebTransport ticket for an already
+// HTTP-authenticated caller, for use as a query parameter since the
+// WebTransport CONNECT request can't carry an Authorization header.
+func (s *httpServer) handleWTTicket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := s.authenticateHTTP(w, r)
+	if !ok {
+		return
+	}
+
+	ticket, err := s.mintWTTicket(id)
+	if err != nil {
+		http.Error(w, "failed to mint ticket", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"ticket": ticket})
+}
+
+// ticketSecret lazily generates this server's random WebTransport ticket
+// signing key on first use.
+func (s *httpServer) ticketSecret() []byte {
+	s.ticketSecretOnce.Do(func() {
+		s.ticketSecretBytes = make([]byte, 32)
+		_, _ = rand.Read(s.ticketSecretBytes)
+	})
+	return s.ticketSecretBytes
+}
@@ -0,0 +1,87 @@
+package sip
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+)
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, header, payload map[string]any) string {
+	t.Helper()
+	headerJSON, _ := json.Marshal(header)
+	payloadJSON, _ := json.Marshal(payload)
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	sum := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatalf("sign RS256 token: %v", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// TestJWTAuthenticatorVerifyRS256 signs a token the way a real identity
+// provider would (SHA-256 DigestInfo prefix included) and checks it passes
+// verify; a VerifyPKCS1v15 call using crypto.Hash(0) instead of
+// crypto.SHA256 would reject every genuine RS256 token.
+func TestJWTAuthenticatorVerifyRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	a := &JWTAuthenticator{jwks: map[string]*rsa.PublicKey{"kid1": &key.PublicKey}}
+	token := signRS256(t, key,
+		map[string]any{"alg": "RS256", "kid": "kid1"},
+		map[string]any{"sub": "alice", "roles": []string{"admin"}},
+	)
+
+	id, err := a.verify(token)
+	if err != nil {
+		t.Fatalf("verify rejected a genuinely-signed RS256 token: %v", err)
+	}
+	if id.Subject != "alice" {
+		t.Errorf("Subject = %q, want %q", id.Subject, "alice")
+	}
+	if len(id.Roles) != 1 || id.Roles[0] != "admin" {
+		t.Errorf("Roles = %v, want [admin]", id.Roles)
+	}
+}
+
+func TestJWTAuthenticatorVerifyHS256(t *testing.T) {
+	a := &JWTAuthenticator{HMACSecret: []byte("shared-secret")}
+
+	headerJSON, _ := json.Marshal(map[string]any{"alg": "HS256"})
+	payloadJSON, _ := json.Marshal(map[string]any{"sub": "bob"})
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	mac := hmac.New(sha256.New, a.HMACSecret)
+	mac.Write([]byte(signingInput))
+	token := signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	id, err := a.verify(token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if id.Subject != "bob" {
+		t.Errorf("Subject = %q, want %q", id.Subject, "bob")
+	}
+}
+
+func TestJWTAuthenticatorVerifyRejectsForgedSignature(t *testing.T) {
+	a := &JWTAuthenticator{HMACSecret: []byte("shared-secret")}
+
+	headerJSON, _ := json.Marshal(map[string]any{"alg": "HS256"})
+	payloadJSON, _ := json.Marshal(map[string]any{"sub": "eve"})
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+	token := signingInput + "." + base64.RawURLEncoding.EncodeToString([]byte("not-a-real-signature"))
+
+	if _, err := a.verify(token); err == nil {
+		t.Fatal("expected verify to reject a forged signature")
+	}
+}
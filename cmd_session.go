@@ -14,6 +14,7 @@ import (
 // an external command in a PTY and bridges its I/O to the browser.
 type cmdSession struct {
 	id            string
+	srv           *httpServer
 	platform      *cmdPlatformPty
 	cols          int
 	rows          int
@@ -23,6 +24,15 @@ type cmdSession struct {
 	closed        bool
 	startTime     time.Time
 	windowChanges chan WindowSize
+	shareOnce     sync.Once
+	shareWriter   string
+	shareReader   string
+	recorder      *recorder
+	filesOnce     sync.Once
+	files         *fileChannel
+	hubOnce       sync.Once
+	hub           *shareHub
+	identity      Identity
 }
 
 func (s *cmdSession) Pty() Pty {
@@ -69,6 +79,10 @@ func (s *cmdSession) Resize(cols, rows int) {
 		_ = s.platform.Resize(cols, rows)
 	}
 
+	if s.recorder != nil {
+		s.recorder.Resize(cols, rows)
+	}
+
 	select {
 	case s.windowChanges <- WindowSize{Width: cols, Height: rows}:
 	default:
@@ -77,6 +91,9 @@ func (s *cmdSession) Resize(cols, rows int) {
 
 // OutputReader returns the reader for terminal output (for handlers).
 func (s *cmdSession) OutputReader() io.Reader {
+	if s.recorder != nil {
+		return &recordingReader{r: s.platform.OutputReader(), rec: s.recorder}
+	}
 	return s.platform.OutputReader()
 }
 
@@ -85,6 +102,43 @@ func (s *cmdSession) InputWriter() io.Writer {
 	return s.platform.InputWriter()
 }
 
+// Share enables multi-viewer access to this session. See the Session
+// interface documentation for details.
+func (s *cmdSession) Share() (writerURL, readerURL string) {
+	if s.srv == nil || !s.srv.config.EnableSharing {
+		return "", ""
+	}
+	s.shareOnce.Do(func() {
+		s.shareWriter, s.shareReader = s.srv.share(s.id, s.ensureHub())
+	})
+	return s.shareWriter, s.shareReader
+}
+
+// ensureHub lazily creates and starts the fan-out hub this session's PTY
+// output is read through.
+func (s *cmdSession) ensureHub() *shareHub {
+	s.hubOnce.Do(func() {
+		s.hub = newShareHub(s.id, s)
+		s.hub.start()
+	})
+	return s.hub
+}
+
+// Files returns the file-transfer channel for this session. See the
+// Session interface documentation for details.
+func (s *cmdSession) Files() FileChannel {
+	return s.ensureFiles()
+}
+
+// ensureFiles lazily creates the file-transfer channel. See webSession's
+// method of the same name for why the HTTP handlers also call this.
+func (s *cmdSession) ensureFiles() *fileChannel {
+	s.filesOnce.Do(func() {
+		s.files = newFileChannel(s.id, s.srv.config.FileTransfer)
+	})
+	return s.files
+}
+
 // CommandHandler creates command sessions for each browser connection.
 type CommandHandler struct {
 	name string
@@ -113,7 +167,10 @@ func newCmdHTTPServer(config Config, handler *CommandHandler) *httpServer {
 	return srv
 }
 
-func (srv *httpServer) createCmdSession(ctx context.Context, initialCols, initialRows int) (*cmdSession, error) {
+// createCmdSession spawns a new command session. identity, if non-zero,
+// identifies the caller that created it, for attribution in the recorder
+// and session hub.
+func (srv *httpServer) createCmdSession(ctx context.Context, initialCols, initialRows int, identity Identity) (*cmdSession, error) {
 	if srv.cmdHandler == nil {
 		return nil, fmt.Errorf("no command handler configured")
 	}
@@ -138,6 +195,7 @@ func (srv *httpServer) createCmdSession(ctx context.Context, initialCols, initia
 
 	session := &cmdSession{
 		id:            fmt.Sprintf("%d", time.Now().UnixNano()),
+		srv:           srv,
 		platform:      platform,
 		cols:          cols,
 		rows:          rows,
@@ -145,7 +203,9 @@ func (srv *httpServer) createCmdSession(ctx context.Context, initialCols, initia
 		ctx:           sessionCtx,
 		startTime:     time.Now(),
 		windowChanges: windowChanges,
+		identity:      identity,
 	}
+	session.recorder = srv.maybeStartRecorder(session, session.id, cols, rows, identity)
 
 	// Monitor process exit
 	go func() {
@@ -154,6 +214,7 @@ func (srv *httpServer) createCmdSession(ctx context.Context, initialCols, initia
 	}()
 
 	srv.sessions.Store(session.id, session)
+	srv.metrics.ptyStarted()
 	logger.Debug("command session created", "session", session.id)
 
 	return session, nil
@@ -169,6 +230,8 @@ func (srv *httpServer) closeCmdSession(session *cmdSession) {
 	session.mu.Unlock()
 
 	duration := time.Since(session.startTime)
+	srv.metrics.observeSessionDuration(duration)
+	srv.metrics.ptyStopped()
 
 	session.cancelFunc()
 
@@ -176,6 +239,10 @@ func (srv *httpServer) closeCmdSession(session *cmdSession) {
 		_ = session.platform.Close()
 	}
 
+	if session.recorder != nil {
+		session.recorder.Close()
+	}
+
 	srv.sessions.Delete(session.id)
 
 	logger.Debug("command session closed",
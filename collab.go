@@ -0,0 +1,257 @@
+package sip
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// Named sessions let several browsers collaborate on one Bubble Tea
+// program: the first connection to request a given name creates the
+// underlying webSession, and every later connection for that name attaches
+// to the same PTY through its shareHub instead of getting one of its own.
+// The creator holds the driver role and its input reaches the PTY; a later
+// connection presenting the session's driver token takes the driver role
+// if no driver is attached, and every other attachment is a read-only
+// spectator. Roles are fixed for the life
+// of a connection; there is no runtime promote/demote API yet, so an
+// operator wanting to hand off control today has to share the driver
+// token out of band.
+
+// namedSession records the webSession backing a collaborative session and
+// the token that grants driver (write) access to it.
+type namedSession struct {
+	webSess     *webSession
+	hub         *shareHub
+	driverToken string
+
+	mu             sync.Mutex
+	driverAttached bool
+}
+
+// claimDriver attempts to become this session's sole driver, enforcing the
+// single-privileged-writer requirement even when several connections
+// present the driver token concurrently. It reports whether the claim
+// succeeded; a connection that loses the race attaches as a spectator
+// instead.
+func (ns *namedSession) claimDriver() bool {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	if ns.driverAttached {
+		return false
+	}
+	ns.driverAttached = true
+	return true
+}
+
+// releaseDriver frees the driver slot claimed by claimDriver, so the next
+// connection presenting the driver token can drive.
+func (ns *namedSession) releaseDriver() {
+	ns.mu.Lock()
+	ns.driverAttached = false
+	ns.mu.Unlock()
+}
+
+// handleNamedSessionWS serves /ws?session=<name>[&token=<driverToken>] when
+// Config.EnableNamedSessions is set. id is the caller's Identity, already
+// authenticated by handleWebSocket.
+func (s *httpServer) handleNamedSessionWS(w http.ResponseWriter, r *http.Request, name string, id Identity) {
+	logger.Info("named session connection", "name", name, "remote", r.RemoteAddr)
+
+	opts := &websocket.AcceptOptions{OriginPatterns: s.config.AllowOrigins}
+	if len(s.config.AllowOrigins) == 0 {
+		opts.OriginPatterns = []string{"*"}
+	}
+
+	conn, err := websocket.Accept(w, r, opts)
+	if err != nil {
+		logger.Error("named session WebSocket accept failed", "err", err, "remote", r.RemoteAddr)
+		return
+	}
+	defer func() { _ = conn.CloseNow() }()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	cols, rows := 80, 24
+	readCtx, readCancel := context.WithTimeout(ctx, 5*time.Second)
+	_, data, err := conn.Read(readCtx)
+	readCancel()
+	if err == nil && len(data) > 0 && data[0] == MsgResize {
+		var resize ResizeMessage
+		if err := json.Unmarshal(data[1:], &resize); err == nil {
+			cols, rows = resize.Cols, resize.Rows
+		}
+	}
+
+	ns, created, err := s.joinNamedSession(name, cols, rows, id)
+	if err != nil {
+		logger.Error("named session creation failed", "err", err, "name", name)
+		_ = conn.Close(websocket.StatusInternalError, err.Error())
+		return
+	}
+
+	role := roleSpectator
+	if created || r.URL.Query().Get("token") == ns.driverToken {
+		if ns.claimDriver() {
+			role = roleDriver
+		}
+	}
+
+	if a := s.config.Authenticator; a != nil {
+		action := "watch"
+		if role == roleDriver {
+			action = "drive"
+		}
+		if !a.Authorize(id, action) {
+			logger.Warn("named session authorization denied", "name", name, "user", id.Subject, "action", action)
+			if role == roleDriver {
+				ns.releaseDriver()
+			}
+			_ = conn.Close(websocket.StatusPolicyViolation, "not authorized")
+			return
+		}
+	}
+
+	viewerCap := 0
+	if !created {
+		viewerCap = s.config.MaxViewersPerSession
+	}
+	sub, replay, ok := ns.hub.attachCapped(role, cols, rows, viewerCap)
+	if !ok {
+		logger.Warn("named session viewer cap reached", "name", name, "max", s.config.MaxViewersPerSession)
+		if role == roleDriver {
+			ns.releaseDriver()
+		}
+		_ = conn.Close(websocket.StatusTryAgainLater, "viewer cap reached")
+		return
+	}
+	defer func() {
+		ns.hub.detach(sub)
+		if role == roleDriver {
+			ns.releaseDriver()
+		}
+		// Re-check under namedMu, the same lock joinNamedSession uses to
+		// decide create-or-join: without it, a new connection could join
+		// between our detach and the delete below, only to have its
+		// session pulled out from under it.
+		s.namedMu.Lock()
+		if ns.hub.subscriberCount() == 0 {
+			s.named.Delete(name)
+			s.reapSession(ns.webSess)
+		}
+		s.namedMu.Unlock()
+	}()
+
+	optionsData, _ := json.Marshal(OptionsMessage{
+		ReadOnly: s.config.ReadOnly || role != roleDriver,
+		Role:     roleQueryName(role),
+	})
+	_ = conn.Write(ctx, websocket.MessageBinary, append([]byte{MsgOptions}, optionsData...))
+	if len(replay) > 0 {
+		s.metrics.addBytesOut(transportWS, len(replay))
+		_ = conn.Write(ctx, websocket.MessageBinary, append([]byte{MsgOutput}, replay...))
+	}
+
+	logger.Info("named session attached",
+		"name", name, "session", ns.webSess.id, "role", roleQueryName(role), "remote", r.RemoteAddr, "user", id.Subject,
+	)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case chunk, ok := <-sub.ch:
+				if !ok {
+					_ = conn.Write(ctx, websocket.MessageBinary, []byte{MsgClose})
+					return
+				}
+				s.metrics.addBytesOut(transportWS, len(chunk))
+				if err := conn.Write(ctx, websocket.MessageBinary, append([]byte{MsgOutput}, chunk...)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		for {
+			_, data, err := conn.Read(ctx)
+			if err != nil {
+				return
+			}
+			if len(data) == 0 {
+				continue
+			}
+			s.metrics.addBytesIn(transportWS, len(data))
+			switch data[0] {
+			case MsgInput:
+				if role == roleDriver && !s.config.ReadOnly {
+					ns.hub.writeInput(data[1:])
+				}
+			case MsgResize:
+				var resize ResizeMessage
+				if err := json.Unmarshal(data[1:], &resize); err == nil {
+					ns.hub.resize(sub, resize.Cols, resize.Rows)
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+}
+
+// joinNamedSession returns the named session for name, creating a fresh
+// webSession the first time name is seen. created reports whether this
+// call did the creating, which makes the caller the session's driver.
+// identity is attributed to the session if this call creates it.
+//
+// The created webSession is rooted in the server's base context rather
+// than this connection's request context, since it must keep running for
+// other spectators after its creator disconnects.
+func (s *httpServer) joinNamedSession(name string, cols, rows int, identity Identity) (*namedSession, bool, error) {
+	s.namedMu.Lock()
+	defer s.namedMu.Unlock()
+
+	if v, ok := s.named.Load(name); ok {
+		return v.(*namedSession), false, nil
+	}
+
+	baseCtx := s.baseCtx
+	if baseCtx == nil {
+		baseCtx = context.Background()
+	}
+
+	webSess, err := s.createSession(baseCtx, s.handler, cols, rows, identity)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ns := &namedSession{
+		webSess:     webSess,
+		hub:         webSess.ensureHub(),
+		driverToken: newShareToken(),
+	}
+	s.named.Store(name, ns)
+	logger.Info("named session created", "name", name, "session", webSess.id, "driver_token", ns.driverToken)
+	return ns, true, nil
+}
+
+func roleQueryName(role shareRole) string {
+	if role == roleDriver {
+		return "drive"
+	}
+	return "watch"
+}
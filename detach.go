@@ -0,0 +1,128 @@
+package sip
+
+import "time"
+
+// reattachCookieName is the cookie handleWebSocket uses to resume a parked
+// session across browser reloads when Config.DetachTimeout > 0.
+const reattachCookieName = "sip_reattach"
+
+// SessionInfo describes one session tracked by a Server, for Server.Sessions().
+type SessionInfo struct {
+	ID        string
+	Cols      int
+	Rows      int
+	StartedAt time.Time
+	Detached  bool
+}
+
+// Sessions returns a snapshot of every session the server currently knows
+// about, including ones parked awaiting reattachment.
+func (s *Server) Sessions() []SessionInfo {
+	if s.server == nil {
+		return nil
+	}
+
+	var infos []SessionInfo
+	s.server.sessions.Range(func(_, v any) bool {
+		switch sess := v.(type) {
+		case *webSession:
+			sess.mu.Lock()
+			infos = append(infos, SessionInfo{
+				ID:        sess.id,
+				Cols:      sess.cols,
+				Rows:      sess.rows,
+				StartedAt: sess.startTime,
+				Detached:  sess.isDetached,
+			})
+			sess.mu.Unlock()
+		case *cmdSession:
+			sess.mu.Lock()
+			infos = append(infos, SessionInfo{
+				ID:        sess.id,
+				Cols:      sess.cols,
+				Rows:      sess.rows,
+				StartedAt: sess.startTime,
+			})
+			sess.mu.Unlock()
+		}
+		return true
+	})
+	return infos
+}
+
+// Kill terminates the session with the given id, whether it is actively
+// connected or parked awaiting reattachment.
+func (s *Server) Kill(id string) bool {
+	if s.server == nil {
+		return false
+	}
+
+	v, ok := s.server.sessions.Load(id)
+	if !ok {
+		return false
+	}
+
+	switch sess := v.(type) {
+	case *webSession:
+		s.server.reapSession(sess)
+	case *cmdSession:
+		s.server.closeCmdSession(sess)
+	default:
+		return false
+	}
+	return true
+}
+
+// detachSession parks session instead of tearing it down, so a later
+// reconnect presenting the same reattach cookie can resume it in place. The
+// PTY keeps running in the background, fanned out through the session's
+// shareHub so its scrollback is available to replay on reattach; if no
+// reconnect arrives within the larger of DetachTimeout and ResumeGrace, the
+// session is reaped.
+func (srv *httpServer) detachSession(session *webSession) {
+	session.mu.Lock()
+	if session.closed || session.isDetached {
+		session.mu.Unlock()
+		return
+	}
+	session.isDetached = true
+	session.detachedAt = time.Now()
+	detachedAt := session.detachedAt
+	token := session.reattachToken
+	session.mu.Unlock()
+
+	session.ensureHub()
+
+	if token != "" {
+		srv.detached.Store(token, session)
+	}
+
+	grace := srv.config.DetachTimeout
+	if srv.config.ResumeGrace > grace {
+		grace = srv.config.ResumeGrace
+	}
+	logger.Debug("session detached", "session", session.id, "timeout", grace)
+
+	time.AfterFunc(grace, func() {
+		session.mu.Lock()
+		// isDetached alone isn't enough: a detach -> reattach -> detach-again
+		// cycle leaves this timer pending, and by the time it fires
+		// isDetached is true again for the *new* cycle. Only reap if this is
+		// still the cycle this timer was scheduled for.
+		expired := session.isDetached && session.detachedAt.Equal(detachedAt)
+		session.mu.Unlock()
+		if expired {
+			logger.Debug("detach timeout expired, reaping session", "session", session.id)
+			srv.reapSession(session)
+		}
+	})
+}
+
+// reattachSession resumes a parked session for a fresh browser connection,
+// clearing its detached state and applying the new viewport.
+func (srv *httpServer) reattachSession(session *webSession, cols, rows int) {
+	session.mu.Lock()
+	session.isDetached = false
+	session.mu.Unlock()
+	session.Resize(cols, rows)
+}
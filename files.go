@@ -0,0 +1,292 @@
+package sip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileChannel lets a session exchange files with its browser client over
+// the same connection used for terminal I/O, multiplexed by MsgFileControl
+// and MsgFileData frames so the terminal stream is never interrupted.
+type FileChannel interface {
+	// OnUpload registers the handler invoked for each file the browser
+	// uploads. r is streamed as data frames arrive; returning an error
+	// aborts the upload.
+	OnUpload(handler func(name string, r io.Reader) error)
+
+	// Download sends a file to the browser under the given name, streaming
+	// from r. It returns an error if no client is currently attached.
+	Download(name string, r io.Reader) error
+}
+
+// fileControlMsg is the JSON payload carried by MsgFileControl frames.
+type fileControlMsg struct {
+	Op   string `json:"op"` // "put" (browser -> server), "get" (server -> browser), "done" (either direction)
+	Name string `json:"name"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// fileChannel is the internal implementation of FileChannel, shared by the
+// default upload handler (a per-session tempdir) and any handler the
+// application registers.
+type fileChannel struct {
+	sessionID string
+	cfg       FileTransferConfig
+
+	mu       sync.Mutex
+	onUpload func(name string, r io.Reader) error
+	pending  map[string]*io.PipeWriter
+	sink     func(msgType byte, payload []byte) error
+	tempDir  string
+}
+
+func newFileChannel(sessionID string, cfg FileTransferConfig) *fileChannel {
+	return &fileChannel{
+		sessionID: sessionID,
+		cfg:       cfg,
+		pending:   make(map[string]*io.PipeWriter),
+	}
+}
+
+func (f *fileChannel) OnUpload(handler func(name string, r io.Reader) error) {
+	f.mu.Lock()
+	f.onUpload = handler
+	f.mu.Unlock()
+}
+
+func (f *fileChannel) Download(name string, r io.Reader) error {
+	f.mu.Lock()
+	sink := f.sink
+	f.mu.Unlock()
+
+	if sink == nil {
+		return fmt.Errorf("sip: no client attached to session %s", f.sessionID)
+	}
+	if f.cfg.Disabled {
+		return fmt.Errorf("sip: file transfer is disabled for session %s", f.sessionID)
+	}
+
+	ctrl, _ := json.Marshal(fileControlMsg{Op: "get", Name: name})
+	if err := sink(MsgFileControl, ctrl); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if werr := sink(MsgFileData, buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	done, _ := json.Marshal(fileControlMsg{Op: "done", Name: name})
+	return sink(MsgFileControl, done)
+}
+
+// attachSink binds the frame writer for whichever connection is currently
+// serving this session; detachSink clears it when that connection ends.
+func (f *fileChannel) attachSink(sink func(msgType byte, payload []byte) error) {
+	f.mu.Lock()
+	f.sink = sink
+	f.mu.Unlock()
+}
+
+func (f *fileChannel) detachSink() {
+	f.mu.Lock()
+	f.sink = nil
+	f.mu.Unlock()
+}
+
+func (f *fileChannel) handleControl(payload []byte) {
+	if f.cfg.Disabled {
+		return
+	}
+
+	var msg fileControlMsg
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		logger.Warn("invalid file-control message", "session", f.sessionID, "err", err)
+		return
+	}
+
+	switch msg.Op {
+	case "put":
+		f.beginUpload(msg)
+	case "done":
+		f.endUpload(msg.Name)
+	}
+}
+
+func (f *fileChannel) handleData(payload []byte) {
+	f.mu.Lock()
+	var pw *io.PipeWriter
+	for _, w := range f.pending {
+		pw = w
+		break
+	}
+	f.mu.Unlock()
+
+	if pw == nil {
+		return
+	}
+	_, _ = pw.Write(payload)
+}
+
+func (f *fileChannel) beginUpload(msg fileControlMsg) {
+	if f.cfg.MaxUploadSize > 0 && msg.Size > f.cfg.MaxUploadSize {
+		logger.Warn("upload rejected: exceeds MaxUploadSize", "session", f.sessionID, "name", msg.Name, "size", msg.Size)
+		return
+	}
+	if !f.nameAllowed(msg.Name) {
+		logger.Warn("upload rejected: name not allowed", "session", f.sessionID, "name", msg.Name)
+		return
+	}
+
+	pr, pw := io.Pipe()
+
+	f.mu.Lock()
+	// MsgFileData frames carry no upload identifier, so handleData can't
+	// tell which pending upload a frame belongs to when more than one is in
+	// flight. Refuse a second concurrent "put" rather than guessing and
+	// silently interleaving two files' data.
+	if len(f.pending) > 0 {
+		f.mu.Unlock()
+		logger.Warn("upload rejected: another upload is already in progress", "session", f.sessionID, "name", msg.Name)
+		_ = pw.CloseWithError(fmt.Errorf("sip: another upload is already in progress"))
+		return
+	}
+	f.pending[msg.Name] = pw
+	handler := f.onUpload
+	f.mu.Unlock()
+
+	if handler == nil {
+		handler = f.defaultUploadHandler
+	}
+
+	go func() {
+		// msg.Size is only the client's declared size; a client that
+		// under-declares it could otherwise write unbounded data into the
+		// handler. Cap the actual bytes read, regardless of which handler
+		// consumes them.
+		var uploadReader io.Reader = pr
+		if f.cfg.MaxUploadSize > 0 {
+			uploadReader = &uploadLimitReader{r: pr, limit: f.cfg.MaxUploadSize}
+		}
+
+		if err := handler(msg.Name, uploadReader); err != nil {
+			logger.Warn("upload handler failed", "session", f.sessionID, "name", msg.Name, "err", err)
+			_ = pr.CloseWithError(err)
+			return
+		}
+		// Drain any bytes the handler chose not to read so the writer
+		// side never blocks on a handler that returned early.
+		_, _ = io.Copy(io.Discard, pr)
+	}()
+}
+
+// uploadLimitReader caps how many bytes may be read from r before returning
+// an error, unlike io.LimitReader which silently truncates: an upload that
+// exceeds MaxUploadSize should be rejected, not quietly saved short and
+// reported as a success.
+type uploadLimitReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+func (lr *uploadLimitReader) Read(p []byte) (int, error) {
+	n, err := lr.r.Read(p)
+	lr.read += int64(n)
+	if lr.read > lr.limit {
+		return n, fmt.Errorf("upload exceeds MaxUploadSize (%d bytes)", lr.limit)
+	}
+	return n, err
+}
+
+func (f *fileChannel) endUpload(name string) {
+	f.mu.Lock()
+	pw, ok := f.pending[name]
+	delete(f.pending, name)
+	f.mu.Unlock()
+
+	if ok {
+		_ = pw.Close()
+	}
+}
+
+// nameAllowed rejects escaping/absolute names and, if AllowedPaths is set,
+// requires a match against one of its glob patterns.
+func (f *fileChannel) nameAllowed(name string) bool {
+	if name == "" || strings.Contains(name, "..") || filepath.IsAbs(name) {
+		return false
+	}
+	if len(f.cfg.AllowedPaths) == 0 {
+		return true
+	}
+	for _, pattern := range f.cfg.AllowedPaths {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultUploadHandler is used when the application never calls OnUpload;
+// it writes uploads into a per-session temp directory.
+func (f *fileChannel) defaultUploadHandler(name string, r io.Reader) error {
+	dir, err := f.ensureTempDir()
+	if err != nil {
+		return err
+	}
+
+	dst, err := os.Create(filepath.Join(dir, filepath.Base(name)))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dst.Close() }()
+
+	_, err = io.Copy(dst, r)
+	return err
+}
+
+func (f *fileChannel) ensureTempDir() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.tempDir != "" {
+		return f.tempDir, nil
+	}
+	dir, err := os.MkdirTemp("", "sip-upload-"+f.sessionID+"-")
+	if err != nil {
+		return "", err
+	}
+	f.tempDir = dir
+	return dir, nil
+}
+
+// sessionFileChannel returns the file channel for an internalSession,
+// creating it if this is the first call (e.g. from the HTTP handlers at
+// connection setup, before the handler has necessarily called Session.Files
+// itself). This way the frame sink always attaches to the same channel the
+// handler later retrieves, rather than one created too late to matter.
+func sessionFileChannel(session internalSession) *fileChannel {
+	switch sess := session.(type) {
+	case *webSession:
+		return sess.ensureFiles()
+	case *cmdSession:
+		return sess.ensureFiles()
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package sip
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+)
+
+// TestFileChannelRejectsConcurrentUploads exercises two "put" messages
+// arriving before either has a matching "done": MsgFileData frames carry no
+// upload identifier, so a second upload accepted concurrently would have its
+// data routed to an arbitrary pending writer and corrupt both files. The
+// second "put" must be rejected instead, leaving the first upload to finish
+// untouched.
+func TestFileChannelRejectsConcurrentUploads(t *testing.T) {
+	f := newFileChannel("sess1", FileTransferConfig{})
+
+	var mu sync.Mutex
+	received := make(map[string][]byte)
+	done := make(chan struct{}, 2)
+	f.OnUpload(func(name string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		mu.Lock()
+		received[name] = data
+		mu.Unlock()
+		done <- struct{}{}
+		return err
+	})
+
+	put := func(name string, size int64) {
+		ctrl, _ := json.Marshal(fileControlMsg{Op: "put", Name: name, Size: size})
+		f.handleControl(ctrl)
+	}
+
+	put("first.txt", 5)
+	put("second.txt", 5)
+
+	f.mu.Lock()
+	pending := len(f.pending)
+	f.mu.Unlock()
+	if pending != 1 {
+		t.Fatalf("len(f.pending) = %d, want 1 (second put should have been rejected)", pending)
+	}
+
+	f.handleData([]byte("hello"))
+	f.endUpload("first.txt")
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := string(received["first.txt"]); got != "hello" {
+		t.Errorf("received[%q] = %q, want %q", "first.txt", got, "hello")
+	}
+	if _, ok := received["second.txt"]; ok {
+		t.Errorf("second.txt should never have been accepted")
+	}
+}
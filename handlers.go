@@ -17,14 +17,17 @@ import (
 
 // Message types for WebSocket/WebTransport communication.
 const (
-	MsgInput   = '0' // Terminal input (client -> server)
-	MsgOutput  = '1' // Terminal output (server -> client)
-	MsgResize  = '2' // Resize terminal
-	MsgPing    = '3' // Ping
-	MsgPong    = '4' // Pong
-	MsgTitle   = '5' // Set window title
-	MsgOptions = '6' // Configuration options
-	MsgClose   = '7' // Session closed (server -> client)
+	MsgInput       = '0' // Terminal input (client -> server)
+	MsgOutput      = '1' // Terminal output (server -> client)
+	MsgResize      = '2' // Resize terminal
+	MsgPing        = '3' // Ping
+	MsgPong        = '4' // Pong
+	MsgTitle       = '5' // Set window title
+	MsgOptions     = '6' // Configuration options
+	MsgClose       = '7' // Session closed (server -> client)
+	MsgFileControl = '8' // File-transfer control frame (JSON, both directions)
+	MsgFileData    = '9' // File-transfer data chunk (both directions)
+	MsgResume      = ':' // Resume a parked session (client -> server), JSON ResumeMessage
 )
 
 const (
@@ -62,6 +65,34 @@ type ResizeMessage struct {
 // OptionsMessage is sent to configure the terminal.
 type OptionsMessage struct {
 	ReadOnly bool `json:"readOnly"`
+
+	// Role is set for named/collaborative sessions (see Config.EnableNamedSessions)
+	// to tell the client whether it was granted "drive" or "watch".
+	// Empty for ordinary, non-shared sessions.
+	Role string `json:"role,omitempty"`
+
+	// Version is the wire protocol version. It is bumped to 2 when
+	// Config.ResumeGrace is enabled, telling the client that SessionID and
+	// ResumeToken are populated and that output frames carry a 4-byte
+	// sequence number it should track and send back as ResumeMessage.LastSeq.
+	Version int `json:"version,omitempty"`
+
+	// SessionID and ResumeToken, set when Config.ResumeGrace > 0, let a
+	// reconnecting client resume this exact session by sending a
+	// ResumeMessage instead of starting a new one.
+	SessionID   string `json:"sessionId,omitempty"`
+	ResumeToken string `json:"resumeToken,omitempty"`
+}
+
+// ResumeMessage is sent by a reconnecting client as its first frame, in
+// place of a ResizeMessage, to rebind to a still-parked session (see
+// Config.ResumeGrace) instead of starting a new one. LastSeq is the
+// sequence number of the last output chunk the client successfully
+// processed, so the server only needs to backfill what it missed.
+type ResumeMessage struct {
+	SessionID string `json:"sessionId"`
+	Token     string `json:"token"`
+	LastSeq   uint64 `json:"lastSeq"`
 }
 
 // internalSession is the interface that both webSession and cmdSession implement
@@ -71,21 +102,35 @@ type internalSession interface {
 	InputWriter() io.Writer
 	Resize(cols, rows int)
 	Done() <-chan struct{}
+	ensureHub() *shareHub
 }
 
 // sessionInfo holds common session metadata for logging.
 type sessionInfo struct {
-	id   string
-	cols int
-	rows int
+	id      string
+	cols    int
+	rows    int
+	subject string
 }
 
 func (s *httpServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	if !s.checkConnectionLimit() {
+	if !s.checkConnectionLimit(transportWS) {
 		http.Error(w, "Maximum connections reached", http.StatusServiceUnavailable)
 		return
 	}
-	defer s.releaseConnection()
+	defer s.releaseConnection(transportWS)
+
+	id, ok := s.authenticateHTTP(w, r)
+	if !ok {
+		return
+	}
+
+	if s.config.EnableNamedSessions {
+		if name := r.URL.Query().Get("session"); name != "" {
+			s.handleNamedSessionWS(w, r, name, id)
+			return
+		}
+	}
 
 	logger.Info("WebSocket connection attempt",
 		"remote", r.RemoteAddr,
@@ -99,8 +144,31 @@ func (s *httpServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		opts.OriginPatterns = []string{"*"}
 	}
 
+	// When detach/reattach is enabled, hand back (or renew) a cookie
+	// identifying this browser's session before upgrading, since the
+	// Set-Cookie header can't be added once the WebSocket handshake response
+	// has been written.
+	var reattachToken string
+	if s.config.DetachTimeout > 0 || s.config.ResumeGrace > 0 {
+		if c, err := r.Cookie(reattachCookieName); err == nil && c.Value != "" {
+			reattachToken = c.Value
+		} else {
+			reattachToken = newShareToken()
+		}
+		if s.config.DetachTimeout > 0 {
+			http.SetCookie(w, &http.Cookie{
+				Name:     reattachCookieName,
+				Value:    reattachToken,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+	}
+
 	conn, err := websocket.Accept(w, r, opts)
 	if err != nil {
+		s.metrics.rejected("origin")
 		logger.Error("WebSocket accept failed", "err", err, "remote", r.RemoteAddr)
 		return
 	}
@@ -110,16 +178,25 @@ func (s *httpServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	defer cancel()
 
 	cols, rows := 80, 24
+	var resumeReq *ResumeMessage
 	readCtx, readCancel := context.WithTimeout(ctx, 5*time.Second)
 	_, data, err := conn.Read(readCtx)
 	readCancel()
 
-	if err == nil && len(data) > 0 && data[0] == MsgResize {
-		var resize ResizeMessage
-		if err := json.Unmarshal(data[1:], &resize); err == nil {
-			cols = resize.Cols
-			rows = resize.Rows
-			logger.Debug("got initial size from browser", "cols", cols, "rows", rows)
+	if err == nil && len(data) > 0 {
+		switch data[0] {
+		case MsgResize:
+			var resize ResizeMessage
+			if err := json.Unmarshal(data[1:], &resize); err == nil {
+				cols = resize.Cols
+				rows = resize.Rows
+				logger.Debug("got initial size from browser", "cols", cols, "rows", rows)
+			}
+		case MsgResume:
+			var resume ResumeMessage
+			if err := json.Unmarshal(data[1:], &resume); err == nil {
+				resumeReq = &resume
+			}
 		}
 	}
 
@@ -132,25 +209,50 @@ func (s *httpServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	if s.cmdHandler != nil {
 		// Command mode: spawn external command
-		cmdSess, err := s.createCmdSession(ctx, cols, rows)
+		cmdSess, err := s.createCmdSession(ctx, cols, rows, id)
 		if err != nil {
 			logger.Error("command session creation failed", "err", err, "remote", r.RemoteAddr)
 			_ = conn.Close(websocket.StatusInternalError, err.Error())
 			return
 		}
 		session = cmdSess
-		info = sessionInfo{id: cmdSess.id, cols: cmdSess.cols, rows: cmdSess.rows}
+		info = sessionInfo{id: cmdSess.id, cols: cmdSess.cols, rows: cmdSess.rows, subject: id.Subject}
 		closeFunc = func() { s.closeCmdSession(cmdSess) }
 	} else {
-		// Bubble Tea mode: run in-process
-		webSess, err := s.createSession(ctx, s.handler, cols, rows)
-		if err != nil {
-			logger.Error("session creation failed", "err", err, "remote", r.RemoteAddr)
-			_ = conn.Close(websocket.StatusInternalError, err.Error())
-			return
+		// Bubble Tea mode: run in-process, reattaching to a parked session
+		// either by an explicit ResumeMessage or the browser's reattach
+		// cookie.
+		var webSess *webSession
+		if resumeReq != nil && s.config.ResumeGrace > 0 {
+			if v, ok := s.detached.Load(resumeReq.Token); ok {
+				if cand, ok := v.(*webSession); ok && cand.id == resumeReq.SessionID {
+					webSess = cand
+					s.detached.Delete(resumeReq.Token)
+					s.reattachSession(webSess, cols, rows)
+					logger.Info("session resumed", "session", webSess.id, "remote", r.RemoteAddr, "last_seq", resumeReq.LastSeq)
+				}
+			}
+		}
+		if webSess == nil && reattachToken != "" {
+			if v, ok := s.detached.Load(reattachToken); ok {
+				webSess = v.(*webSession)
+				s.detached.Delete(reattachToken)
+				s.reattachSession(webSess, cols, rows)
+				logger.Info("session reattached", "session", webSess.id, "remote", r.RemoteAddr)
+			}
+		}
+		if webSess == nil {
+			var err error
+			webSess, err = s.createSession(ctx, s.handler, cols, rows, id)
+			if err != nil {
+				logger.Error("session creation failed", "err", err, "remote", r.RemoteAddr)
+				_ = conn.Close(websocket.StatusInternalError, err.Error())
+				return
+			}
+			webSess.reattachToken = reattachToken
 		}
 		session = webSess
-		info = sessionInfo{id: webSess.id, cols: webSess.cols, rows: webSess.rows}
+		info = sessionInfo{id: webSess.id, cols: webSess.cols, rows: webSess.rows, subject: id.Subject}
 		closeFunc = func() { s.closeSession(webSess) }
 	}
 
@@ -168,17 +270,45 @@ func (s *httpServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		"remote", r.RemoteAddr,
 		"cols", info.cols,
 		"rows", info.rows,
+		"user", info.subject,
 	)
 
-	optionsData, _ := json.Marshal(OptionsMessage{ReadOnly: s.config.ReadOnly})
+	optionsMsg := OptionsMessage{ReadOnly: s.config.ReadOnly}
+	if webSess, ok := session.(*webSession); ok && s.config.ResumeGrace > 0 {
+		optionsMsg.Version = 2
+		optionsMsg.SessionID = webSess.id
+		optionsMsg.ResumeToken = webSess.reattachToken
+	}
+	optionsData, _ := json.Marshal(optionsMsg)
 	_ = conn.Write(ctx, websocket.MessageBinary, append([]byte{MsgOptions}, optionsData...))
 
+	if fc := sessionFileChannel(session); fc != nil {
+		fc.attachSink(func(msgType byte, payload []byte) error {
+			return conn.Write(ctx, websocket.MessageBinary, append([]byte{msgType}, payload...))
+		})
+		defer fc.detachSink()
+	}
+
+	var resumeAfterSeq uint64
+	if resumeReq != nil {
+		resumeAfterSeq = resumeReq.LastSeq
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
 		defer cancel()
+		// The hub becomes the PTY's sole reader whenever something else
+		// might also attach to it concurrently: Share() (which can be
+		// called by the handler at any time) or a detach/resume reconnect.
+		// Reading OutputReader() directly here too would split the PTY's
+		// single output stream across two independent readers.
+		if s.config.EnableSharing || s.config.DetachTimeout > 0 || s.config.ResumeGrace > 0 {
+			s.streamOutputToWebSocketHub(ctx, conn, session.ensureHub(), info, resumeAfterSeq)
+			return
+		}
 		s.streamOutputToWebSocket(ctx, conn, session, info)
 	}()
 
@@ -192,11 +322,16 @@ func (s *httpServer) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *httpServer) handleWebTransport(w http.ResponseWriter, r *http.Request) {
-	if !s.checkConnectionLimit() {
+	if !s.checkConnectionLimit(transportWT) {
 		http.Error(w, "Maximum connections reached", http.StatusServiceUnavailable)
 		return
 	}
-	defer s.releaseConnection()
+	defer s.releaseConnection(transportWT)
+
+	id, ok := s.authenticateWebTransport(w, r)
+	if !ok {
+		return
+	}
 
 	logger.Info("WebTransport connection attempt",
 		"remote", r.RemoteAddr,
@@ -221,17 +356,26 @@ func (s *httpServer) handleWebTransport(w http.ResponseWriter, r *http.Request)
 	defer func() { _ = stream.Close() }()
 
 	cols, rows := 80, 24
+	var resumeReq *ResumeMessage
 	lenBuf := make([]byte, 4)
 	if _, err := io.ReadFull(stream, lenBuf); err == nil {
 		length := binary.BigEndian.Uint32(lenBuf)
 		if length < 1024 {
 			data := make([]byte, length)
-			if _, err := io.ReadFull(stream, data); err == nil && len(data) > 0 && data[0] == MsgResize {
-				var resize ResizeMessage
-				if err := json.Unmarshal(data[1:], &resize); err == nil {
-					cols = resize.Cols
-					rows = resize.Rows
-					logger.Debug("got initial size from browser (WT)", "cols", cols, "rows", rows)
+			if _, err := io.ReadFull(stream, data); err == nil && len(data) > 0 {
+				switch data[0] {
+				case MsgResize:
+					var resize ResizeMessage
+					if err := json.Unmarshal(data[1:], &resize); err == nil {
+						cols = resize.Cols
+						rows = resize.Rows
+						logger.Debug("got initial size from browser (WT)", "cols", cols, "rows", rows)
+					}
+				case MsgResume:
+					var resume ResumeMessage
+					if err := json.Unmarshal(data[1:], &resume); err == nil {
+						resumeReq = &resume
+					}
 				}
 			}
 		}
@@ -246,23 +390,43 @@ func (s *httpServer) handleWebTransport(w http.ResponseWriter, r *http.Request)
 
 	if s.cmdHandler != nil {
 		// Command mode: spawn external command
-		cmdSess, err := s.createCmdSession(ctx, cols, rows)
+		cmdSess, err := s.createCmdSession(ctx, cols, rows, id)
 		if err != nil {
 			logger.Error("command session creation failed", "err", err, "remote", r.RemoteAddr)
 			return
 		}
 		session = cmdSess
-		info = sessionInfo{id: cmdSess.id, cols: cmdSess.cols, rows: cmdSess.rows}
+		info = sessionInfo{id: cmdSess.id, cols: cmdSess.cols, rows: cmdSess.rows, subject: id.Subject}
 		closeFunc = func() { s.closeCmdSession(cmdSess) }
 	} else {
-		// Bubble Tea mode: run in-process
-		webSess, err := s.createSession(ctx, s.handler, cols, rows)
-		if err != nil {
-			logger.Error("session creation failed", "err", err, "remote", r.RemoteAddr)
-			return
+		// Bubble Tea mode: run in-process, reattaching to a parked session
+		// when the client opens with a ResumeMessage instead of a
+		// ResizeMessage (see the matching resumeReq handling in
+		// handleWebSocket).
+		var webSess *webSession
+		if resumeReq != nil && s.config.ResumeGrace > 0 {
+			if v, ok := s.detached.Load(resumeReq.Token); ok {
+				if cand, ok := v.(*webSession); ok && cand.id == resumeReq.SessionID {
+					webSess = cand
+					s.detached.Delete(resumeReq.Token)
+					s.reattachSession(webSess, cols, rows)
+					logger.Info("session resumed (WT)", "session", webSess.id, "remote", r.RemoteAddr, "last_seq", resumeReq.LastSeq)
+				}
+			}
+		}
+		if webSess == nil {
+			var err error
+			webSess, err = s.createSession(ctx, s.handler, cols, rows, id)
+			if err != nil {
+				logger.Error("session creation failed", "err", err, "remote", r.RemoteAddr)
+				return
+			}
+			if s.config.ResumeGrace > 0 {
+				webSess.reattachToken = newShareToken()
+			}
 		}
 		session = webSess
-		info = sessionInfo{id: webSess.id, cols: webSess.cols, rows: webSess.rows}
+		info = sessionInfo{id: webSess.id, cols: webSess.cols, rows: webSess.rows, subject: id.Subject}
 		closeFunc = func() { s.closeSession(webSess) }
 	}
 
@@ -280,17 +444,42 @@ func (s *httpServer) handleWebTransport(w http.ResponseWriter, r *http.Request)
 		"remote", r.RemoteAddr,
 		"cols", info.cols,
 		"rows", info.rows,
+		"user", info.subject,
 	)
 
-	optionsData, _ := json.Marshal(OptionsMessage{ReadOnly: s.config.ReadOnly})
+	optionsMsg := OptionsMessage{ReadOnly: s.config.ReadOnly}
+	if webSess, ok := session.(*webSession); ok && s.config.ResumeGrace > 0 {
+		optionsMsg.Version = 2
+		optionsMsg.SessionID = webSess.id
+		optionsMsg.ResumeToken = webSess.reattachToken
+	}
+	optionsData, _ := json.Marshal(optionsMsg)
 	_ = writeFramed(stream, append([]byte{MsgOptions}, optionsData...))
 
+	if fc := sessionFileChannel(session); fc != nil {
+		fc.attachSink(func(msgType byte, payload []byte) error {
+			return writeFramed(stream, append([]byte{msgType}, payload...))
+		})
+		defer fc.detachSink()
+	}
+
+	var resumeAfterSeq uint64
+	if resumeReq != nil {
+		resumeAfterSeq = resumeReq.LastSeq
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
 	go func() {
 		defer wg.Done()
 		defer cancel()
+		// See the matching comment in handleWebSocket: once Share() can be
+		// called, the hub must be the PTY's sole reader.
+		if s.config.EnableSharing || s.config.DetachTimeout > 0 || s.config.ResumeGrace > 0 {
+			s.streamOutputToWebTransportHub(ctx, stream, session.ensureHub(), info, resumeAfterSeq)
+			return
+		}
 		s.streamOutputToWebTransport(ctx, stream, session, info)
 	}()
 
@@ -315,6 +504,7 @@ func (s *httpServer) streamOutputToWebSocket(ctx context.Context, conn *websocke
 	defer writeBufPool.Put(msgPtr)
 
 	var totalBytes int64
+	out := session.OutputReader()
 
 	for {
 		select {
@@ -328,7 +518,7 @@ func (s *httpServer) streamOutputToWebSocket(ctx context.Context, conn *websocke
 		default:
 		}
 
-		n, err := session.OutputReader().Read(buf)
+		n, err := out.Read(buf)
 		if err != nil {
 			logger.Debug("output closed", "session", info.id, "bytes_sent", totalBytes, "error", err)
 			_ = conn.Write(ctx, websocket.MessageBinary, []byte{MsgClose})
@@ -343,6 +533,7 @@ func (s *httpServer) streamOutputToWebSocket(ctx context.Context, conn *websocke
 		}
 
 		totalBytes += int64(n)
+		s.metrics.addBytesOut(transportWS, n)
 		copy(msg[1:], buf[:n])
 		if err := conn.Write(ctx, websocket.MessageBinary, msg[:n+1]); err != nil {
 			logger.Debug("WebSocket write error", "session", info.id, "err", err)
@@ -351,6 +542,111 @@ func (s *httpServer) streamOutputToWebSocket(ctx context.Context, conn *websocke
 	}
 }
 
+// streamOutputToWebSocketHub streams a session's output to conn through its
+// shareHub rather than reading session.OutputReader() directly. This is used
+// instead of streamOutputToWebSocket when Config.EnableSharing is set,
+// Config.DetachTimeout > 0 or Config.ResumeGrace > 0, so the main browser
+// connection and a shared or parked/reattached session share the same PTY
+// reader instead of racing on it. afterSeq, when non-zero, is the sequence
+// number the client last saw (from a ResumeMessage); the replay is trimmed
+// to just what it missed instead of the full scrollback. When wire protocol
+// v2 is in use (see outputFrame), every MsgOutput frame carries its 4-byte
+// sequence number so the client can track where to resume from next.
+func (s *httpServer) streamOutputToWebSocketHub(ctx context.Context, conn *websocket.Conn, hub *shareHub, info sessionInfo, afterSeq uint64) {
+	sub, replay, seq, exact := hub.attachResume(roleDriver, info.cols, info.rows, afterSeq)
+	defer hub.detach(sub)
+
+	if afterSeq != 0 && !exact {
+		logger.Debug("resume window missed, falling back to full replay", "session", info.id, "requested_seq", afterSeq)
+	}
+
+	if len(replay) > 0 {
+		s.metrics.addBytesOut(transportWS, len(replay))
+		_ = conn.Write(ctx, websocket.MessageBinary, s.outputFrame(seq, replay))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Debug("WebSocket output stopped (context)", "session", info.id)
+			return
+		case chunk, ok := <-sub.ch:
+			if !ok {
+				_ = conn.Write(ctx, websocket.MessageBinary, []byte{MsgClose})
+				return
+			}
+			seq++
+			s.metrics.addBytesOut(transportWS, len(chunk))
+			if err := conn.Write(ctx, websocket.MessageBinary, s.outputFrame(seq, chunk)); err != nil {
+				logger.Debug("WebSocket write error", "session", info.id, "err", err)
+				return
+			}
+		}
+	}
+}
+
+// seqFrame builds a [msgType][4-byte big-endian seq][payload] frame, the
+// wire format used for output streamed through a resumable shareHub. The
+// prefix holds the low 32 bits of seq.
+func seqFrame(msgType byte, seq uint64, payload []byte) []byte {
+	frame := make([]byte, 5+len(payload))
+	frame[0] = msgType
+	binary.BigEndian.PutUint32(frame[1:5], uint32(seq))
+	copy(frame[5:], payload)
+	return frame
+}
+
+// outputFrame builds a MsgOutput frame for hub-streamed output. The
+// sequence-numbered seqFrame format is used only where wire protocol v2 is
+// announced in OptionsMessage (ResumeGrace > 0 in Bubble Tea mode); every
+// other connection gets a plain [MsgOutput][payload] frame.
+func (s *httpServer) outputFrame(seq uint64, payload []byte) []byte {
+	if s.config.ResumeGrace > 0 && s.cmdHandler == nil {
+		return seqFrame(MsgOutput, seq, payload)
+	}
+	return append([]byte{MsgOutput}, payload...)
+}
+
+// streamOutputToWebTransportHub streams a session's output to stream through
+// its shareHub rather than reading session.OutputReader() directly, mirroring
+// streamOutputToWebSocketHub. afterSeq, when non-zero, is the sequence number
+// the client last saw (from a ResumeMessage); the replay is trimmed to just
+// what it missed instead of the full scrollback. When wire protocol v2 is in
+// use (see outputFrame), every MsgOutput frame carries its 4-byte sequence
+// number so the client can track where to resume from next.
+func (s *httpServer) streamOutputToWebTransportHub(ctx context.Context, stream *webtransport.Stream, hub *shareHub, info sessionInfo, afterSeq uint64) {
+	sub, replay, seq, exact := hub.attachResume(roleDriver, info.cols, info.rows, afterSeq)
+	defer hub.detach(sub)
+
+	if afterSeq != 0 && !exact {
+		logger.Debug("resume window missed, falling back to full replay", "session", info.id, "requested_seq", afterSeq)
+	}
+
+	if len(replay) > 0 {
+		s.metrics.addBytesOut(transportWT, len(replay))
+		_ = writeFramed(stream, s.outputFrame(seq, replay))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Debug("WebTransport output stopped (context)", "session", info.id)
+			return
+		case chunk, ok := <-sub.ch:
+			if !ok {
+				_ = writeFramed(stream, []byte{MsgClose})
+				return
+			}
+			seq++
+			s.metrics.addBytesOut(transportWT, len(chunk))
+			if err := writeFramed(stream, s.outputFrame(seq, chunk)); err != nil {
+				logger.Debug("WebTransport write error", "session", info.id, "err", err)
+				return
+			}
+		}
+	}
+}
+
 func (s *httpServer) streamOutputToWebTransport(ctx context.Context, stream *webtransport.Stream, session internalSession, info sessionInfo) {
 	bufPtr := readBufPool.Get().(*[]byte)
 	buf := *bufPtr
@@ -361,6 +657,7 @@ func (s *httpServer) streamOutputToWebTransport(ctx context.Context, stream *web
 	defer writeBufPool.Put(framePtr)
 
 	var totalBytes int64
+	out := session.OutputReader()
 
 	for {
 		select {
@@ -374,7 +671,7 @@ func (s *httpServer) streamOutputToWebTransport(ctx context.Context, stream *web
 		default:
 		}
 
-		n, err := session.OutputReader().Read(buf)
+		n, err := out.Read(buf)
 		if err != nil {
 			logger.Debug("output closed", "session", info.id, "bytes_sent", totalBytes, "error", err)
 			_ = writeFramed(stream, []byte{MsgClose})
@@ -393,6 +690,7 @@ func (s *httpServer) streamOutputToWebTransport(ctx context.Context, stream *web
 		}
 
 		totalBytes += int64(n)
+		s.metrics.addBytesOut(transportWT, n)
 
 		msgLen := n + 1
 		binary.BigEndian.PutUint32(frame[0:4], uint32(msgLen))
@@ -427,6 +725,7 @@ func (s *httpServer) handleWebSocketInput(ctx context.Context, conn *websocket.C
 
 		totalBytes += int64(len(data))
 		msgCount++
+		s.metrics.addBytesIn(transportWS, len(data))
 		s.processInput(data, session, info)
 	}
 }
@@ -471,6 +770,7 @@ func (s *httpServer) handleWebTransportInput(ctx context.Context, stream *webtra
 
 		totalBytes += int64(length)
 		msgCount++
+		s.metrics.addBytesIn(transportWT, int(length))
 		s.processInput(msg, session, info)
 	}
 }
@@ -486,6 +786,9 @@ func (s *httpServer) processInput(data []byte, session internalSession, info ses
 	switch msgType {
 	case MsgInput:
 		if !s.config.ReadOnly {
+			if rec := sessionRecorder(session); rec != nil {
+				rec.Input(string(payload))
+			}
 			_, _ = session.InputWriter().Write(payload)
 		}
 
@@ -503,6 +806,16 @@ func (s *httpServer) processInput(data []byte, session internalSession, info ses
 
 	case MsgPing:
 		// Pong handled at transport layer
+
+	case MsgFileControl:
+		if fc := sessionFileChannel(session); fc != nil {
+			fc.handleControl(payload)
+		}
+
+	case MsgFileData:
+		if fc := sessionFileChannel(session); fc != nil {
+			fc.handleData(payload)
+		}
 	}
 }
 
@@ -0,0 +1,224 @@
+package sip
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// transport identifies which connection kind a metric applies to, used as
+// the Prometheus "transport" label.
+type transport string
+
+const (
+	transportWS transport = "ws"
+	transportWT transport = "wt"
+)
+
+// durationBuckets are the histogram bucket boundaries (seconds) for
+// sip_session_duration_seconds, skewed toward the long-lived terminal
+// sessions sip typically serves rather than short HTTP requests.
+var durationBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 1800, 3600, 7200}
+
+// durationHistogram is a minimal Prometheus-style histogram: cumulative
+// per-bucket counts plus a running sum and count. Observations happen once
+// per session close, so a plain mutex (rather than lock-free buckets) is
+// simple and fast enough.
+type durationHistogram struct {
+	mu      sync.Mutex
+	buckets []uint64 // cumulative count for durationBuckets[i], same length
+	sum     float64
+	count   uint64
+}
+
+func newDurationHistogram() *durationHistogram {
+	return &durationHistogram{buckets: make([]uint64, len(durationBuckets))}
+}
+
+func (h *durationHistogram) observe(seconds float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.buckets == nil {
+		h.buckets = make([]uint64, len(durationBuckets))
+	}
+	for i, le := range durationBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// metrics holds the counters and gauges behind /metrics. Every field is
+// safe to use at its zero value, so httpServer need not initialize it.
+type metrics struct {
+	activeWS, activeWT int64  // atomic
+	connectionsTotal   uint64 // atomic
+
+	rejectedLimit, rejectedAuth, rejectedOrigin uint64 // atomic
+
+	bytesInWS, bytesInWT, bytesOutWS, bytesOutWT uint64 // atomic
+
+	ptyAlive int64 // atomic
+
+	sessionDuration durationHistogram
+}
+
+func (m *metrics) connectionAdmitted(t transport) {
+	atomic.AddUint64(&m.connectionsTotal, 1)
+	switch t {
+	case transportWS:
+		atomic.AddInt64(&m.activeWS, 1)
+	case transportWT:
+		atomic.AddInt64(&m.activeWT, 1)
+	}
+}
+
+func (m *metrics) connectionReleased(t transport) {
+	switch t {
+	case transportWS:
+		atomic.AddInt64(&m.activeWS, -1)
+	case transportWT:
+		atomic.AddInt64(&m.activeWT, -1)
+	}
+}
+
+func (m *metrics) rejected(reason string) {
+	switch reason {
+	case "limit":
+		atomic.AddUint64(&m.rejectedLimit, 1)
+	case "auth":
+		atomic.AddUint64(&m.rejectedAuth, 1)
+	case "origin":
+		atomic.AddUint64(&m.rejectedOrigin, 1)
+	}
+}
+
+func (m *metrics) addBytesIn(t transport, n int) {
+	if n <= 0 {
+		return
+	}
+	if t == transportWT {
+		atomic.AddUint64(&m.bytesInWT, uint64(n))
+	} else {
+		atomic.AddUint64(&m.bytesInWS, uint64(n))
+	}
+}
+
+func (m *metrics) addBytesOut(t transport, n int) {
+	if n <= 0 {
+		return
+	}
+	if t == transportWT {
+		atomic.AddUint64(&m.bytesOutWT, uint64(n))
+	} else {
+		atomic.AddUint64(&m.bytesOutWS, uint64(n))
+	}
+}
+
+func (m *metrics) ptyStarted() { atomic.AddInt64(&m.ptyAlive, 1) }
+func (m *metrics) ptyStopped() { atomic.AddInt64(&m.ptyAlive, -1) }
+
+func (m *metrics) observeSessionDuration(d time.Duration) {
+	m.sessionDuration.observe(d.Seconds())
+}
+
+// render writes the current metrics in Prometheus text exposition format.
+func (m *metrics) render(w *strings.Builder, certExpirySeconds float64, hasCert bool) {
+	writeMetric := func(name, help, typ string, samples ...string) {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+		for _, s := range samples {
+			w.WriteString(s)
+			w.WriteString("\n")
+		}
+	}
+
+	writeMetric("sip_connections_active", "Currently open terminal connections by transport.", "gauge",
+		fmt.Sprintf(`sip_connections_active{transport="ws"} %d`, atomic.LoadInt64(&m.activeWS)),
+		fmt.Sprintf(`sip_connections_active{transport="wt"} %d`, atomic.LoadInt64(&m.activeWT)),
+	)
+
+	writeMetric("sip_connections_total", "Terminal connections admitted since start.", "counter",
+		fmt.Sprintf("sip_connections_total %d", atomic.LoadUint64(&m.connectionsTotal)),
+	)
+
+	writeMetric("sip_connections_rejected_total", "Terminal connections rejected before a session was created.", "counter",
+		fmt.Sprintf(`sip_connections_rejected_total{reason="limit"} %d`, atomic.LoadUint64(&m.rejectedLimit)),
+		fmt.Sprintf(`sip_connections_rejected_total{reason="auth"} %d`, atomic.LoadUint64(&m.rejectedAuth)),
+		fmt.Sprintf(`sip_connections_rejected_total{reason="origin"} %d`, atomic.LoadUint64(&m.rejectedOrigin)),
+	)
+
+	m.sessionDuration.mu.Lock()
+	histSamples := make([]string, 0, len(durationBuckets)+2)
+	for i, le := range durationBuckets {
+		var n uint64
+		if m.sessionDuration.buckets != nil {
+			n = m.sessionDuration.buckets[i]
+		}
+		histSamples = append(histSamples, fmt.Sprintf(`sip_session_duration_seconds_bucket{le="%g"} %d`, le, n))
+	}
+	histSamples = append(histSamples,
+		fmt.Sprintf(`sip_session_duration_seconds_bucket{le="+Inf"} %d`, m.sessionDuration.count),
+		fmt.Sprintf("sip_session_duration_seconds_sum %g", m.sessionDuration.sum),
+		fmt.Sprintf("sip_session_duration_seconds_count %d", m.sessionDuration.count),
+	)
+	m.sessionDuration.mu.Unlock()
+	writeMetric("sip_session_duration_seconds", "How long terminal sessions ran before closing.", "histogram", histSamples...)
+
+	writeMetric("sip_bytes_in_total", "Bytes read from client input by transport.", "counter",
+		fmt.Sprintf(`sip_bytes_in_total{transport="ws"} %d`, atomic.LoadUint64(&m.bytesInWS)),
+		fmt.Sprintf(`sip_bytes_in_total{transport="wt"} %d`, atomic.LoadUint64(&m.bytesInWT)),
+	)
+
+	writeMetric("sip_bytes_out_total", "Bytes written to client output by transport.", "counter",
+		fmt.Sprintf(`sip_bytes_out_total{transport="ws"} %d`, atomic.LoadUint64(&m.bytesOutWS)),
+		fmt.Sprintf(`sip_bytes_out_total{transport="wt"} %d`, atomic.LoadUint64(&m.bytesOutWT)),
+	)
+
+	writeMetric("sip_pty_alive", "PTYs currently running across all sessions.", "gauge",
+		fmt.Sprintf("sip_pty_alive %d", atomic.LoadInt64(&m.ptyAlive)),
+	)
+
+	if hasCert {
+		writeMetric("sip_cert_expiry_seconds", "Seconds until the server's self-signed TLS certificate expires.", "gauge",
+			fmt.Sprintf("sip_cert_expiry_seconds %g", certExpirySeconds),
+		)
+	}
+}
+
+// metricsPath returns the configured (or default) path /metrics is served
+// under, or "" if Config.MetricsEnabled is false.
+func (s *httpServer) metricsPath() string {
+	if !s.config.MetricsEnabled {
+		return ""
+	}
+	if s.config.MetricsPath != "" {
+		return s.config.MetricsPath
+	}
+	return "/metrics"
+}
+
+// handleMetrics serves the current metrics in Prometheus text exposition
+// format, gated behind Config.Authenticator like every other route.
+func (s *httpServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticateHTTP(w, r); !ok {
+		return
+	}
+
+	var certExpiry float64
+	hasCert := s.certInfo != nil
+	if hasCert {
+		certExpiry = time.Until(s.certInfo.NotAfter).Seconds()
+	}
+
+	var sb strings.Builder
+	s.metrics.render(&sb, certExpiry, hasCert)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = w.Write([]byte(sb.String()))
+}
@@ -0,0 +1,46 @@
+package sip
+
+import "testing"
+
+func TestDurationHistogramObserve(t *testing.T) {
+	h := newDurationHistogram()
+
+	h.observe(0.5)  // falls in every bucket
+	h.observe(10)   // falls in buckets >= 15
+	h.observe(3600) // falls only in the last two buckets
+
+	if h.count != 3 {
+		t.Fatalf("count = %d, want 3", h.count)
+	}
+	if h.sum != 0.5+10+3600 {
+		t.Fatalf("sum = %v, want %v", h.sum, 0.5+10+3600)
+	}
+
+	for i, le := range durationBuckets {
+		want := uint64(0)
+		if 0.5 <= le {
+			want++
+		}
+		if 10 <= le {
+			want++
+		}
+		if 3600 <= le {
+			want++
+		}
+		if h.buckets[i] != want {
+			t.Errorf("bucket le=%g = %d, want %d", le, h.buckets[i], want)
+		}
+	}
+}
+
+func TestDurationHistogramEmpty(t *testing.T) {
+	h := newDurationHistogram()
+	if h.count != 0 || h.sum != 0 {
+		t.Fatalf("new histogram should start empty, got count=%d sum=%v", h.count, h.sum)
+	}
+	for i, b := range h.buckets {
+		if b != 0 {
+			t.Fatalf("bucket %d = %d, want 0", i, b)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package sip
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// recorderQueueSize bounds how many unwritten frames a recorder can queue
+// before it starts dropping them rather than blocking the PTY reader.
+const recorderQueueSize = 256
+
+// recordEvent is one asciicast v2 event line: [elapsed, kind, data].
+type recordEvent struct {
+	elapsed float64
+	kind    string
+	data    string
+}
+
+// recorder writes a session's terminal stream to an asciicast v2 file on a
+// dedicated goroutine so a slow disk never blocks the PTY reader.
+type recorder struct {
+	path      string
+	start     time.Time
+	withInput bool
+
+	events chan recordEvent
+	done   chan struct{}
+
+	mu      sync.Mutex
+	dropped int
+	closed  bool
+}
+
+// newRecorder creates path and writes the asciicast v2 header line. subject,
+// if non-empty, is the authenticated Identity driving the session and is
+// recorded in the header so a recording can be attributed to a user.
+func newRecorder(path string, cols, rows int, withInput bool, subject string) (*recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+
+	header := map[string]any{
+		"version":   2,
+		"width":     cols,
+		"height":    rows,
+		"timestamp": time.Now().Unix(),
+		"env":       map[string]string{"TERM": "xterm-256color"},
+	}
+	if subject != "" {
+		header["user"] = subject
+	}
+	if err := json.NewEncoder(f).Encode(header); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to write recording header: %w", err)
+	}
+
+	r := &recorder{
+		path:      path,
+		start:     time.Now(),
+		withInput: withInput,
+		events:    make(chan recordEvent, recorderQueueSize),
+		done:      make(chan struct{}),
+	}
+	go r.run(f)
+	return r, nil
+}
+
+func (r *recorder) run(f *os.File) {
+	defer close(r.done)
+	defer func() { _ = f.Close() }()
+
+	enc := json.NewEncoder(f)
+	for ev := range r.events {
+		if err := enc.Encode([]any{ev.elapsed, ev.kind, ev.data}); err != nil {
+			logger.Warn("recorder write failed, stopping", "path", r.path, "err", err)
+			return
+		}
+	}
+}
+
+func (r *recorder) emit(kind, data string) {
+	ev := recordEvent{elapsed: time.Since(r.start).Seconds(), kind: kind, data: data}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	select {
+	case r.events <- ev:
+	default:
+		r.dropped++
+		logger.Warn("recorder falling behind, dropping frame", "path", r.path, "kind", kind, "dropped_total", r.dropped)
+	}
+}
+
+// Output records an output chunk.
+func (r *recorder) Output(chunk string) {
+	r.emit("o", chunk)
+}
+
+// Input records a keystroke chunk, if input recording is enabled.
+func (r *recorder) Input(chunk string) {
+	if r.withInput {
+		r.emit("i", chunk)
+	}
+}
+
+// Resize records a terminal resize event.
+func (r *recorder) Resize(cols, rows int) {
+	r.emit("r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Close stops accepting new events and waits for the writer goroutine to
+// flush and close the underlying file.
+func (r *recorder) Close() {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+	<-r.done
+}
+
+// recordingReader tees PTY output through a recorder before handing it to
+// the caller, so recording never changes what the caller observes. PTY
+// reads can split a multi-byte UTF-8 rune across two chunks, so an
+// incomplete trailing rune is buffered and prepended to the next chunk
+// rather than recorded as-is.
+type recordingReader struct {
+	r       io.Reader
+	rec     *recorder
+	pending []byte
+}
+
+func (t *recordingReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		chunk := append(t.pending, p[:n]...)
+		safe := utf8SafeLen(chunk)
+		if safe > 0 {
+			t.rec.Output(string(chunk[:safe]))
+		}
+		t.pending = append(t.pending[:0], chunk[safe:]...)
+	}
+	if err != nil && len(t.pending) > 0 {
+		// No more data is coming; flush whatever is left even if it isn't a
+		// complete rune, rather than silently losing it.
+		t.rec.Output(string(t.pending))
+		t.pending = nil
+	}
+	return n, err
+}
+
+// utf8SafeLen returns the length of the longest prefix of b that ends on a
+// complete UTF-8 rune boundary, so a trailing partial multi-byte sequence
+// can be held back and completed by the next chunk.
+func utf8SafeLen(b []byte) int {
+	n := len(b)
+	for back := 1; back <= utf8.UTFMax && back <= n; back++ {
+		c := b[n-back]
+		if c < utf8.RuneSelf {
+			return n // ASCII tail byte: nothing pending before it
+		}
+		if utf8.RuneStart(c) {
+			if utf8.FullRune(b[n-back:]) {
+				return n // the trailing rune is fully present
+			}
+			return n - back // trailing rune is incomplete; hold it back
+		}
+	}
+	return n
+}
+
+// maybeStartRecorder creates a recorder for a newly created session if
+// RecordDir is configured and RecordFilter (if any) accepts it. identity is
+// the caller that created the session, attributed in the recording header.
+func (s *httpServer) maybeStartRecorder(sess Session, id string, cols, rows int, identity Identity) *recorder {
+	if s.config.RecordDir == "" {
+		return nil
+	}
+	if s.config.RecordFilter != nil && !s.config.RecordFilter(sess) {
+		return nil
+	}
+
+	path := filepath.Join(s.config.RecordDir, fmt.Sprintf("%s-%d.cast", id, time.Now().Unix()))
+	rec, err := newRecorder(path, cols, rows, s.config.RecordInput, identity.Subject)
+	if err != nil {
+		logger.Error("failed to start recorder", "session", id, "err", err)
+		return nil
+	}
+
+	logger.Info("recording session", "session", id, "path", path, "user", identity.Subject)
+	return rec
+}
+
+// sessionRecorder returns the recorder attached to an internalSession, if
+// recording is active for it.
+func sessionRecorder(session internalSession) *recorder {
+	switch sess := session.(type) {
+	case *webSession:
+		return sess.recorder
+	case *cmdSession:
+		return sess.recorder
+	}
+	return nil
+}
+
+// recordingIndexEntry describes one recorded session in the GET /recordings
+// index.
+type recordingIndexEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// handleRecordingsIndex lists the available .cast files as JSON, so an
+// operator (or a player page) can discover recordings without filesystem
+// access.
+func (s *httpServer) handleRecordingsIndex(w http.ResponseWriter, r *http.Request) {
+	if s.config.RecordDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if _, ok := s.authenticateHTTP(w, r); !ok {
+		return
+	}
+
+	entries, err := os.ReadDir(s.config.RecordDir)
+	if err != nil {
+		http.Error(w, "failed to list recordings", http.StatusInternalServerError)
+		return
+	}
+
+	index := make([]recordingIndexEntry, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".cast") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		index = append(index, recordingIndexEntry{Name: e.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(index)
+}
+
+// handleRecording streams a previously recorded .cast file so it can be
+// served directly to an asciinema player.
+func (s *httpServer) handleRecording(w http.ResponseWriter, r *http.Request) {
+	if s.config.RecordDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if _, ok := s.authenticateHTTP(w, r); !ok {
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/recordings/")
+	if name == "" || !strings.HasSuffix(name, ".cast") || strings.ContainsAny(name, "/\\") {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(s.config.RecordDir, name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer func() { _ = f.Close() }()
+
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	_, _ = io.Copy(w, f)
+}
@@ -0,0 +1,26 @@
+package sip
+
+import "testing"
+
+func TestUtf8SafeLen(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"empty", "", 0},
+		{"ascii only", "hello", 5},
+		{"complete multi-byte rune", "héllo", len("héllo")},
+		{"trailing incomplete 2-byte rune", "hi\xc3", 2},
+		{"trailing incomplete 3-byte rune", "hi\xe2\x82", 2},
+		{"trailing incomplete 4-byte rune", "hi\xf0\x9f\x92", 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := utf8SafeLen([]byte(tc.in)); got != tc.want {
+				t.Errorf("utf8SafeLen(%q) = %d, want %d", tc.in, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package sip
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/yamux"
+)
+
+// relayProtocolVersion is bumped whenever the handshake or framing on the
+// relay control stream changes incompatibly.
+const relayProtocolVersion = 1
+
+// relayKeepaliveInterval is how often a keepalive is written to the relay
+// control stream so the relay (and any intermediate proxy) knows the
+// tunnel is still alive.
+const relayKeepaliveInterval = 30 * time.Second
+
+// relayHandshake is sent as a single JSON line on the first yamux stream
+// opened by the client to register this server with the relay.
+type relayHandshake struct {
+	Type    string `json:"type"`
+	Token   string `json:"token"`
+	Slug    string `json:"slug"`
+	Version int    `json:"version"`
+}
+
+// ServeRelay dials the configured relay (Config.Relay), opens a yamux
+// session over a persistent TLS connection, and serves the same index,
+// static, and WebSocket routes used by Serve/ServeWithProgram for every
+// inbound virtual HTTP request the relay forwards as a yamux stream. This
+// lets a sip server be reached at a public relay URL without the operator
+// provisioning TLS or port-forwarding.
+//
+// WebTransport stays disabled in relay mode: QUIC is a UDP protocol and
+// can't be carried over the TCP+yamux tunnel, so browsers connecting
+// through a relay always fall back to the WebSocket transport.
+func (s *Server) ServeRelay(ctx context.Context, handler ProgramHandler) error {
+	if s.config.Relay.Address == "" {
+		return fmt.Errorf("sip: ServeRelay requires Config.Relay.Address")
+	}
+
+	s.handler = handler
+	s.server = newHTTPServer(s.config, handler)
+	return s.server.serveRelay(ctx)
+}
+
+func (s *httpServer) serveRelay(ctx context.Context) error {
+	s.baseCtx = ctx
+	relayCfg := s.config.Relay
+
+	logger.Info("dialing relay", "addr", relayCfg.Address, "slug", relayCfg.Slug)
+	conn, err := tls.Dial("tcp", relayCfg.Address, &tls.Config{})
+	if err != nil {
+		return fmt.Errorf("failed to dial relay: %w", err)
+	}
+
+	session, err := yamux.Client(conn, nil)
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to establish yamux session: %w", err)
+	}
+	defer func() { _ = session.Close() }()
+
+	control, err := session.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open relay control stream: %w", err)
+	}
+	defer func() { _ = control.Close() }()
+
+	handshake := relayHandshake{
+		Type:    "register",
+		Token:   relayCfg.AuthToken,
+		Slug:    relayCfg.Slug,
+		Version: relayProtocolVersion,
+	}
+	if err := json.NewEncoder(control).Encode(handshake); err != nil {
+		return fmt.Errorf("failed to send relay handshake: %w", err)
+	}
+
+	ack := bufio.NewReader(control)
+	line, err := ack.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("failed to read relay handshake ack: %w", err)
+	}
+	logger.Info("relay tunnel established", "ack", line)
+
+	go s.relayKeepalive(ctx, control)
+
+	mux := s.relayMux()
+
+	errChan := make(chan error, 1)
+	go func() {
+		for {
+			stream, err := session.Accept()
+			if err != nil {
+				errChan <- fmt.Errorf("relay session closed: %w", err)
+				return
+			}
+			go s.serveRelayStream(mux, stream)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		logger.Info("shutting down relay tunnel")
+		return nil
+	case err := <-errChan:
+		return err
+	}
+}
+
+// relayKeepalive periodically writes a newline-delimited keepalive on the
+// control stream so the relay can detect a dead tunnel promptly.
+func (s *httpServer) relayKeepalive(ctx context.Context, control net.Conn) {
+	ticker := time.NewTicker(relayKeepaliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := json.NewEncoder(control).Encode(relayHandshake{Type: "ping"}); err != nil {
+				logger.Warn("relay keepalive failed", "err", err)
+				return
+			}
+		}
+	}
+}
+
+// relayMux builds the subset of routes reachable through the relay tunnel:
+// the index page, static assets, and the WebSocket terminal endpoint.
+// /cert-hash and /webtransport are omitted since there is no local
+// self-signed certificate or QUIC listener to advertise in relay mode.
+func (s *httpServer) relayMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.handleIndex)
+	mux.HandleFunc("/static/", s.handleStatic)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("OK"))
+	})
+	if s.config.EnableSharing {
+		mux.HandleFunc(s.sharePrefix(), s.handleShareWS)
+	}
+	if s.config.RecordDir != "" {
+		mux.HandleFunc("/recordings", s.handleRecordingsIndex)
+		mux.HandleFunc("/recordings/", s.handleRecording)
+	}
+	return mux
+}
+
+// serveRelayStream treats one yamux stream as a single virtual HTTP
+// connection from a browser, routing it through mux the same way the
+// direct HTTP listener would.
+func (s *httpServer) serveRelayStream(mux *http.ServeMux, stream net.Conn) {
+	defer func() { _ = stream.Close() }()
+	_ = http.Serve(&singleConnListener{conn: stream}, mux)
+}
+
+// singleConnListener adapts a single already-accepted net.Conn into a
+// net.Listener so it can be handed to http.Serve, which is the standard
+// way to run the stdlib HTTP server over one pre-established connection
+// (here, a yamux stream the relay opened for us).
+type singleConnListener struct {
+	conn   net.Conn
+	served bool
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	if l.served {
+		return nil, io.EOF
+	}
+	l.served = true
+	return l.conn, nil
+}
+
+func (l *singleConnListener) Close() error   { return nil }
+func (l *singleConnListener) Addr() net.Addr { return l.conn.LocalAddr() }
@@ -0,0 +1,126 @@
+package sip
+
+import (
+	"context"
+	"time"
+)
+
+// Session resumption lets a WebSocket/WebTransport client that drops and
+// reconnects (WiFi blip, laptop sleep) rebind to its still-running PTY
+// instead of losing it, the way detach.go's cookie-based reattach already
+// does for a same-browser page reload. It builds on that mechanism rather
+// than replacing it: the same parked *webSession, looked up in
+// httpServer.detached, is reused, but here the session id and resume token
+// travel explicitly in the wire protocol (MsgOptions, then a client-sent
+// MsgResume) instead of a Set-Cookie, so WebTransport clients — which
+// cannot attach a cookie to the CONNECT request — can resume too. Output is
+// replayed from the sequence the client last saw rather than a fixed-size
+// scrollback, via a small ring of sequence-numbered chunks kept alongside
+// shareHub's byte scrollback.
+
+// resumeRingSize bounds how many bytes of sequence-numbered output a hub
+// retains for resume backfill. It mirrors shareScrollbackSize so a resumed
+// session can replay at least as much as a fresh attach would.
+const resumeRingSize = shareScrollbackSize
+
+// resumeChunk is one sequence-numbered output chunk retained in a hub's
+// resume ring.
+type resumeChunk struct {
+	seq  uint64
+	data []byte
+}
+
+// trimResumeRing drops the oldest chunks once chunks holds more than
+// resumeRingSize bytes total.
+func trimResumeRing(chunks []resumeChunk) []resumeChunk {
+	size := 0
+	for _, c := range chunks {
+		size += len(c.data)
+	}
+	for size > resumeRingSize && len(chunks) > 1 {
+		size -= len(chunks[0].data)
+		chunks = chunks[1:]
+	}
+	return chunks
+}
+
+// attachResume is like shareHub.attach, but trims the replay to the chunks
+// broadcast after afterSeq when the ring still retains them, instead of the
+// whole scrollback. exact is false when afterSeq has already aged out of
+// the ring, in which case replay falls back to the full scrollback exactly
+// like attach. An afterSeq of 0 means "no resume in progress": replay is
+// then the full scrollback as well, and exact is true.
+func (h *shareHub) attachResume(role shareRole, cols, rows int, afterSeq uint64) (sub *shareSubscriber, replay []byte, seq uint64, exact bool) {
+	sub = &shareSubscriber{
+		role: role,
+		ch:   make(chan []byte, shareSubscriberBacklog),
+		cols: cols,
+		rows: rows,
+	}
+
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	seq = h.resumeSeq
+
+	if afterSeq == 0 || len(h.resumeChunks) == 0 || afterSeq < h.resumeChunks[0].seq-1 {
+		if len(h.scrollback) > 0 {
+			replay = append([]byte(ansiClearScreen), h.scrollback...)
+		}
+		h.mu.Unlock()
+		h.recalcSize()
+		return sub, replay, seq, afterSeq == 0
+	}
+
+	for _, c := range h.resumeChunks {
+		if c.seq > afterSeq {
+			replay = append(replay, c.data...)
+		}
+	}
+	h.mu.Unlock()
+
+	h.recalcSize()
+	return sub, replay, seq, true
+}
+
+// resumeSweepInterval is how often sweepResumable checks for parked
+// sessions whose ResumeGrace has elapsed.
+const resumeSweepInterval = 5 * time.Second
+
+// sweepResumable periodically reaps detached sessions that have sat parked
+// longer than their grace period (the larger of Config.ResumeGrace and
+// Config.DetachTimeout, as in detachSession), as a backstop alongside
+// detachSession's own per-session timer. It runs until ctx is cancelled.
+func (s *httpServer) sweepResumable(ctx context.Context) {
+	if s.config.ResumeGrace <= 0 {
+		return
+	}
+	grace := s.config.ResumeGrace
+	if s.config.DetachTimeout > grace {
+		grace = s.config.DetachTimeout
+	}
+
+	ticker := time.NewTicker(resumeSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.detached.Range(func(_, v any) bool {
+				session, ok := v.(*webSession)
+				if !ok {
+					return true
+				}
+				session.mu.Lock()
+				expired := session.isDetached && time.Since(session.detachedAt) > grace
+				session.mu.Unlock()
+				if expired {
+					logger.Debug("resume grace expired, reaping session", "session", session.id)
+					s.reapSession(session)
+				}
+				return true
+			})
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package sip
+
+import "testing"
+
+func TestTrimResumeRing(t *testing.T) {
+	makeChunk := func(seq uint64, size int) resumeChunk {
+		return resumeChunk{seq: seq, data: make([]byte, size)}
+	}
+
+	t.Run("under limit keeps everything", func(t *testing.T) {
+		chunks := []resumeChunk{makeChunk(1, 10), makeChunk(2, 10)}
+		got := trimResumeRing(chunks)
+		if len(got) != 2 {
+			t.Fatalf("len(got) = %d, want 2", len(got))
+		}
+	})
+
+	t.Run("over limit drops oldest first", func(t *testing.T) {
+		chunks := []resumeChunk{
+			makeChunk(1, resumeRingSize),
+			makeChunk(2, resumeRingSize),
+			makeChunk(3, 1),
+		}
+		got := trimResumeRing(chunks)
+		if len(got) == 0 || got[0].seq == 1 {
+			t.Fatalf("expected oldest chunk (seq 1) to be dropped, got seqs %v", seqsOf(got))
+		}
+		if got[len(got)-1].seq != 3 {
+			t.Fatalf("expected newest chunk (seq 3) to survive, got seqs %v", seqsOf(got))
+		}
+	})
+
+	t.Run("never drops the last remaining chunk", func(t *testing.T) {
+		chunks := []resumeChunk{makeChunk(1, resumeRingSize*2)}
+		got := trimResumeRing(chunks)
+		if len(got) != 1 {
+			t.Fatalf("len(got) = %d, want 1 (single oversized chunk must be kept)", len(got))
+		}
+	})
+}
+
+func seqsOf(chunks []resumeChunk) []uint64 {
+	seqs := make([]uint64, len(chunks))
+	for i, c := range chunks {
+		seqs[i] = c.seq
+	}
+	return seqs
+}
@@ -42,6 +42,17 @@ type httpServer struct {
 	sessions   sync.Map
 	connCount  int32
 	certInfo   *CertInfo
+	cmdHandler *CommandHandler
+	shares     sync.Map // share token -> *shareEntry
+	detached   sync.Map // reattach token -> *webSession
+	named      sync.Map // session name -> *namedSession
+	namedMu    sync.Mutex
+	baseCtx    context.Context
+
+	ticketSecretOnce  sync.Once
+	ticketSecretBytes []byte
+
+	metrics metrics
 }
 
 func newHTTPServer(config Config, handler ProgramHandler) *httpServer {
@@ -52,6 +63,8 @@ func newHTTPServer(config Config, handler ProgramHandler) *httpServer {
 }
 
 func (s *httpServer) start(ctx context.Context) error {
+	s.baseCtx = ctx
+
 	httpPort := s.config.Port
 	wtPortNum := 7682
 	if p, err := strconv.Atoi(s.config.Port); err == nil {
@@ -84,7 +97,12 @@ func (s *httpServer) start(ctx context.Context) error {
 		_, _ = w.Write([]byte("OK"))
 	})
 
-	httpMux.HandleFunc("/cert-hash", func(w http.ResponseWriter, _ *http.Request) {
+	httpMux.HandleFunc("/wt-ticket", s.handleWTTicket)
+
+	httpMux.HandleFunc("/cert-hash", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.authenticateHTTP(w, r); !ok {
+			return
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("Cache-Control", "no-store")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -99,6 +117,25 @@ func (s *httpServer) start(ctx context.Context) error {
 		})
 	})
 
+	if s.config.EnableSharing {
+		prefix := s.sharePrefix()
+		httpMux.HandleFunc(prefix, s.handleShareWS)
+		logger.Info("session sharing enabled", "prefix", prefix)
+	}
+
+	if s.config.RecordDir != "" {
+		httpMux.HandleFunc("/recordings", s.handleRecordingsIndex)
+		httpMux.HandleFunc("/recordings/", s.handleRecording)
+		logger.Info("session recording enabled", "dir", s.config.RecordDir)
+	}
+
+	if path := s.metricsPath(); path != "" {
+		httpMux.HandleFunc(path, s.handleMetrics)
+		logger.Info("metrics enabled", "path", path)
+	}
+
+	go s.sweepResumable(ctx)
+
 	wtMux := http.NewServeMux()
 	wtMux.HandleFunc("/webtransport", s.handleWebTransport)
 
@@ -162,6 +199,10 @@ func (s *httpServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, ok := s.authenticateHTTP(w, r); !ok {
+		return
+	}
+
 	logger.Debug("serving index", "remote", r.RemoteAddr)
 
 	data, err := staticFiles.ReadFile("static/index.html")
@@ -175,6 +216,10 @@ func (s *httpServer) handleIndex(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *httpServer) handleStatic(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authenticateHTTP(w, r); !ok {
+		return
+	}
+
 	path := strings.TrimPrefix(r.URL.Path, "/")
 	data, err := staticFiles.ReadFile(path)
 	if err != nil {
@@ -204,24 +249,28 @@ func (s *httpServer) handleStatic(w http.ResponseWriter, r *http.Request) {
 	_, _ = w.Write(data)
 }
 
-func (s *httpServer) checkConnectionLimit() bool {
+func (s *httpServer) checkConnectionLimit(t transport) bool {
 	if s.config.MaxConnections <= 0 {
+		s.metrics.connectionAdmitted(t)
 		return true
 	}
 	newCount := s.incrementConnCount()
 	if int(newCount) > s.config.MaxConnections {
 		s.decrementConnCount()
+		s.metrics.rejected("limit")
 		logger.Warn("connection limit reached",
 			"current", newCount-1,
 			"max", s.config.MaxConnections,
 		)
 		return false
 	}
+	s.metrics.connectionAdmitted(t)
 	logger.Debug("connection accepted", "count", newCount)
 	return true
 }
 
-func (s *httpServer) releaseConnection() {
+func (s *httpServer) releaseConnection(t transport) {
+	s.metrics.connectionReleased(t)
 	if s.config.MaxConnections <= 0 {
 		return
 	}
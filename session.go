@@ -14,6 +14,7 @@ import (
 // webSession implements the Session interface for web terminal connections.
 type webSession struct {
 	id            string
+	srv           *httpServer
 	program       *tea.Program
 	platform      *platformPty
 	cols          int
@@ -25,6 +26,18 @@ type webSession struct {
 	startTime     time.Time
 	started       chan struct{}
 	windowChanges chan WindowSize
+	shareOnce     sync.Once
+	shareWriter   string
+	shareReader   string
+	recorder      *recorder
+	filesOnce     sync.Once
+	files         *fileChannel
+	hubOnce       sync.Once
+	hub           *shareHub
+	isDetached    bool
+	detachedAt    time.Time
+	reattachToken string
+	identity      Identity
 }
 
 func (s *webSession) Pty() Pty {
@@ -71,6 +84,10 @@ func (s *webSession) Resize(cols, rows int) {
 		_ = s.platform.Resize(cols, rows)
 	}
 
+	if s.recorder != nil {
+		s.recorder.Resize(cols, rows)
+	}
+
 	select {
 	case s.windowChanges <- WindowSize{Width: cols, Height: rows}:
 	default:
@@ -87,6 +104,9 @@ func (s *webSession) WaitForStart() {
 
 // OutputReader returns the reader for terminal output (for handlers).
 func (s *webSession) OutputReader() io.Reader {
+	if s.recorder != nil {
+		return &recordingReader{r: s.platform.OutputReader(), rec: s.recorder}
+	}
 	return s.platform.OutputReader()
 }
 
@@ -95,7 +115,50 @@ func (s *webSession) InputWriter() io.Writer {
 	return s.platform.InputWriter()
 }
 
-func (srv *httpServer) createSession(ctx context.Context, handler ProgramHandler, initialCols, initialRows int) (*webSession, error) {
+// Share enables multi-viewer access to this session. See the Session
+// interface documentation for details.
+func (s *webSession) Share() (writerURL, readerURL string) {
+	if s.srv == nil || !s.srv.config.EnableSharing {
+		return "", ""
+	}
+	s.shareOnce.Do(func() {
+		s.shareWriter, s.shareReader = s.srv.share(s.id, s.ensureHub())
+	})
+	return s.shareWriter, s.shareReader
+}
+
+// ensureHub lazily creates and starts the fan-out hub this session's PTY
+// output is read through. Share() and detach/reattach both attach to the
+// same hub so there is only ever one reader on the PTY master.
+func (s *webSession) ensureHub() *shareHub {
+	s.hubOnce.Do(func() {
+		s.hub = newShareHub(s.id, s)
+		s.hub.start()
+	})
+	return s.hub
+}
+
+// Files returns the file-transfer channel for this session. See the
+// Session interface documentation for details.
+func (s *webSession) Files() FileChannel {
+	return s.ensureFiles()
+}
+
+// ensureFiles lazily creates the file-transfer channel. It's also called by
+// the HTTP handlers at connection setup, before the handler has necessarily
+// called Files() itself, so the frame sink can be attached to the channel
+// the handler will later retrieve rather than silently binding to nothing.
+func (s *webSession) ensureFiles() *fileChannel {
+	s.filesOnce.Do(func() {
+		s.files = newFileChannel(s.id, s.srv.config.FileTransfer)
+	})
+	return s.files
+}
+
+// createSession starts a new Bubble Tea session. identity, if non-zero,
+// identifies the caller that created it, for attribution in the recorder
+// and session hub.
+func (srv *httpServer) createSession(ctx context.Context, handler ProgramHandler, initialCols, initialRows int, identity Identity) (*webSession, error) {
 	cols, rows := initialCols, initialRows
 	if cols <= 0 {
 		cols = 80
@@ -111,12 +174,28 @@ func (srv *httpServer) createSession(ctx context.Context, handler ProgramHandler
 		return nil, fmt.Errorf("failed to create PTY: %w", err)
 	}
 
-	sessionCtx, cancel := context.WithCancel(ctx)
+	// A session that can outlive this connection (detach/reattach or
+	// resume) must not be rooted in this connection's request-scoped ctx:
+	// that ctx is cancelled the moment this first connection drops, which
+	// would permanently cancel Session.Context() on every later reattach
+	// even though the session is still alive. Root it in the server's base
+	// context instead, the same as joinNamedSession already does for
+	// collaborative sessions.
+	rootCtx := ctx
+	if srv.config.DetachTimeout > 0 || srv.config.ResumeGrace > 0 {
+		rootCtx = srv.baseCtx
+		if rootCtx == nil {
+			rootCtx = context.Background()
+		}
+	}
+
+	sessionCtx, cancel := context.WithCancel(rootCtx)
 	started := make(chan struct{})
 	windowChanges := make(chan WindowSize, 1)
 
 	session := &webSession{
 		id:            fmt.Sprintf("%d", time.Now().UnixNano()),
+		srv:           srv,
 		platform:      platform,
 		cols:          cols,
 		rows:          rows,
@@ -125,7 +204,9 @@ func (srv *httpServer) createSession(ctx context.Context, handler ProgramHandler
 		startTime:     time.Now(),
 		started:       started,
 		windowChanges: windowChanges,
+		identity:      identity,
 	}
+	session.recorder = srv.maybeStartRecorder(session, session.id, cols, rows, identity)
 
 	// Call the handler with the session to create the program
 	// The handler should use MakeOptions(session) to configure I/O
@@ -153,21 +234,39 @@ func (srv *httpServer) createSession(ctx context.Context, handler ProgramHandler
 	}()
 
 	srv.sessions.Store(session.id, session)
+	srv.metrics.ptyStarted()
 	logger.Debug("session created", "session", session.id)
 
 	return session, nil
 }
 
+// closeSession is called whenever a browser connection for session ends.
+// With DetachTimeout and ResumeGrace both 0 (the legacy default) it tears
+// the session down right away; otherwise it parks the session so a
+// reconnect can reattach to it.
 func (srv *httpServer) closeSession(session *webSession) {
+	if srv.config.DetachTimeout <= 0 && srv.config.ResumeGrace <= 0 {
+		srv.reapSession(session)
+		return
+	}
+	srv.detachSession(session)
+}
+
+// reapSession fully tears a session down: quits the program, closes its
+// PTY and recorder, and removes it from bookkeeping.
+func (srv *httpServer) reapSession(session *webSession) {
 	session.mu.Lock()
 	if session.closed {
 		session.mu.Unlock()
 		return
 	}
 	session.closed = true
+	token := session.reattachToken
 	session.mu.Unlock()
 
 	duration := time.Since(session.startTime)
+	srv.metrics.observeSessionDuration(duration)
+	srv.metrics.ptyStopped()
 
 	if session.program != nil {
 		session.program.Quit()
@@ -179,6 +278,13 @@ func (srv *httpServer) closeSession(session *webSession) {
 		_ = session.platform.Close()
 	}
 
+	if session.recorder != nil {
+		session.recorder.Close()
+	}
+
+	if token != "" {
+		srv.detached.Delete(token)
+	}
 	srv.sessions.Delete(session.id)
 
 	logger.Debug("session closed",
@@ -0,0 +1,412 @@
+package sip
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// Multi-viewer sharing lets a single PTY-backed session be observed (and,
+// for the designated driver, controlled) by several concurrent browser
+// clients. A session opts in by calling Session.Share, which mints a
+// writer token and a read-only token and registers a shareHub that fans
+// out the session's output to every attached viewer.
+
+const (
+	// shareScrollbackSize bounds how much output is replayed to a viewer
+	// that attaches mid-session.
+	shareScrollbackSize = 64 * 1024
+
+	// shareSubscriberBacklog is how many unflushed chunks a slow viewer can
+	// accumulate before it is resynced instead of blocking the PTY reader.
+	shareSubscriberBacklog = 64
+
+	defaultSharePathPrefix = "/share/"
+
+	// ansiClearScreen repositions the cursor and clears the viewport so a
+	// late-joining or resynced viewer sees a coherent screen.
+	ansiClearScreen = "\x1b[H\x1b[2J"
+)
+
+type shareRole int
+
+const (
+	roleSpectator shareRole = iota
+	roleDriver
+)
+
+// shareEntry maps a single share token to the hub it attaches to and the
+// role that token grants.
+type shareEntry struct {
+	hub  *shareHub
+	role shareRole
+}
+
+// shareSubscriber is one attached viewer or driver connection.
+type shareSubscriber struct {
+	role shareRole
+	ch   chan []byte
+	cols int
+	rows int
+}
+
+// shareHub owns the single goroutine that drains a session's PTY output
+// and broadcasts it to every attached subscriber, coordinating resize to
+// the smallest connected viewport.
+type shareHub struct {
+	id      string
+	session internalSession
+
+	mu           sync.Mutex
+	started      bool
+	subscribers  map[*shareSubscriber]struct{}
+	scrollback   []byte
+	resumeSeq    uint64
+	resumeChunks []resumeChunk
+}
+
+func newShareHub(id string, session internalSession) *shareHub {
+	return &shareHub{
+		id:          id,
+		session:     session,
+		subscribers: make(map[*shareSubscriber]struct{}),
+	}
+}
+
+// start begins fanning out PTY output. It is safe to call multiple times;
+// only the first call spawns the pump goroutine.
+func (h *shareHub) start() {
+	h.mu.Lock()
+	if h.started {
+		h.mu.Unlock()
+		return
+	}
+	h.started = true
+	h.mu.Unlock()
+
+	go h.pump()
+}
+
+func (h *shareHub) pump() {
+	buf := make([]byte, readBufSize)
+	out := h.session.OutputReader()
+	for {
+		n, err := out.Read(buf)
+		if n > 0 {
+			h.broadcast(append([]byte(nil), buf[:n]...))
+		}
+		if err != nil {
+			logger.Debug("share hub pump stopped", "session", h.id, "err", err)
+			h.closeAll()
+			return
+		}
+	}
+}
+
+func (h *shareHub) broadcast(chunk []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.scrollback = append(h.scrollback, chunk...)
+	if over := len(h.scrollback) - shareScrollbackSize; over > 0 {
+		h.scrollback = h.scrollback[over:]
+	}
+
+	h.resumeSeq++
+	h.resumeChunks = append(h.resumeChunks, resumeChunk{seq: h.resumeSeq, data: chunk})
+	h.resumeChunks = trimResumeRing(h.resumeChunks)
+
+	for sub := range h.subscribers {
+		select {
+		case sub.ch <- chunk:
+		default:
+			// Slow client: drop its backlog and resync with a clear rather
+			// than blocking the shared PTY reader on a single straggler.
+			h.drainLocked(sub)
+			resync := append([]byte(ansiClearScreen), chunk...)
+			select {
+			case sub.ch <- resync:
+			default:
+				logger.Warn("share subscriber still behind after resync", "session", h.id)
+			}
+		}
+	}
+}
+
+func (h *shareHub) drainLocked(sub *shareSubscriber) {
+	for {
+		select {
+		case <-sub.ch:
+		default:
+			return
+		}
+	}
+}
+
+func (h *shareHub) closeAll() {
+	h.mu.Lock()
+	subs := h.subscribers
+	h.subscribers = make(map[*shareSubscriber]struct{})
+	h.mu.Unlock()
+
+	for sub := range subs {
+		close(sub.ch)
+	}
+}
+
+// attach registers a viewer and returns a bounded scrollback replay (with a
+// leading clear) so it doesn't start on a blank screen.
+func (h *shareHub) attach(role shareRole, cols, rows int) (*shareSubscriber, []byte) {
+	sub, replay, _ := h.attachCapped(role, cols, rows, 0)
+	return sub, replay
+}
+
+// attachCapped is like attach, but checks max (0 = unlimited) and inserts
+// the new subscriber in the same critical section, so a capacity check and
+// the attach it guards can't race with a concurrent attach that would
+// otherwise let the subscriber count slip past max. ok reports whether the
+// subscriber was attached; the caller must not use sub or replay if ok is
+// false.
+func (h *shareHub) attachCapped(role shareRole, cols, rows, max int) (sub *shareSubscriber, replay []byte, ok bool) {
+	h.mu.Lock()
+	if max > 0 && len(h.subscribers) >= max {
+		h.mu.Unlock()
+		return nil, nil, false
+	}
+
+	sub = &shareSubscriber{
+		role: role,
+		ch:   make(chan []byte, shareSubscriberBacklog),
+		cols: cols,
+		rows: rows,
+	}
+	h.subscribers[sub] = struct{}{}
+	if len(h.scrollback) > 0 {
+		replay = append([]byte(ansiClearScreen), h.scrollback...)
+	}
+	h.mu.Unlock()
+
+	h.recalcSize()
+	return sub, replay, true
+}
+
+// resize updates a subscriber's known viewport and recomputes the shared
+// session size from the smallest attached viewer.
+func (h *shareHub) resize(sub *shareSubscriber, cols, rows int) {
+	h.mu.Lock()
+	sub.cols, sub.rows = cols, rows
+	h.mu.Unlock()
+	h.recalcSize()
+}
+
+// subscriberCount reports how many viewers are currently attached.
+func (h *shareHub) subscriberCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subscribers)
+}
+
+func (h *shareHub) detach(sub *shareSubscriber) {
+	h.mu.Lock()
+	delete(h.subscribers, sub)
+	h.mu.Unlock()
+	h.recalcSize()
+}
+
+// recalcSize resizes the underlying session to the smallest viewport
+// across all attached viewers, so no single large viewer corrupts the
+// display for everyone else.
+func (h *shareHub) recalcSize() {
+	h.mu.Lock()
+	var minCols, minRows int
+	for sub := range h.subscribers {
+		if sub.cols <= 0 || sub.rows <= 0 {
+			continue
+		}
+		if minCols == 0 || sub.cols < minCols {
+			minCols = sub.cols
+		}
+		if minRows == 0 || sub.rows < minRows {
+			minRows = sub.rows
+		}
+	}
+	h.mu.Unlock()
+
+	if minCols > 0 && minRows > 0 {
+		h.session.Resize(minCols, minRows)
+	}
+}
+
+func (h *shareHub) writeInput(p []byte) {
+	_, _ = h.session.InputWriter().Write(p)
+}
+
+func newShareToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *httpServer) sharePrefix() string {
+	prefix := s.config.SharePathPrefix
+	if prefix == "" {
+		prefix = defaultSharePathPrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// share registers share tokens for an already-running shareHub and returns
+// the writer and reader URLs operators can hand out to other browsers. The
+// hub is owned by the session (see webSession.ensureHub) so it can be
+// reused by other features, such as detach/reattach, that also need to
+// fan out the same PTY output.
+func (s *httpServer) share(id string, hub *shareHub) (writerURL, readerURL string) {
+	hub.start()
+
+	writerToken := newShareToken()
+	readerToken := newShareToken()
+	s.shares.Store(writerToken, &shareEntry{hub: hub, role: roleDriver})
+	s.shares.Store(readerToken, &shareEntry{hub: hub, role: roleSpectator})
+
+	scheme := "http"
+	if s.config.TLSCert != "" {
+		scheme = "https"
+	}
+	host := s.config.Host
+	if host == "" {
+		host = "localhost"
+	}
+	base := fmt.Sprintf("%s://%s:%s%s", scheme, host, s.config.Port, s.sharePrefix())
+	return base + writerToken, base + readerToken
+}
+
+// handleShareWS accepts a viewer/driver WebSocket connection under the
+// shared-session path prefix and attaches it to the matching shareHub.
+func (s *httpServer) handleShareWS(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, s.sharePrefix())
+	token = strings.TrimSuffix(token, "/")
+
+	v, ok := s.shares.Load(token)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	entry := v.(*shareEntry)
+
+	id, ok := s.authenticateHTTP(w, r)
+	if !ok {
+		return
+	}
+	if a := s.config.Authenticator; a != nil {
+		action := "watch"
+		if entry.role == roleDriver {
+			action = "drive"
+		}
+		if !a.Authorize(id, action) {
+			logger.Warn("share authorization denied", "session", entry.hub.id, "user", id.Subject, "action", action)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	if !s.checkConnectionLimit(transportWS) {
+		http.Error(w, "Maximum connections reached", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.releaseConnection(transportWS)
+
+	opts := &websocket.AcceptOptions{OriginPatterns: s.config.AllowOrigins}
+	if len(s.config.AllowOrigins) == 0 {
+		opts.OriginPatterns = []string{"*"}
+	}
+
+	conn, err := websocket.Accept(w, r, opts)
+	if err != nil {
+		logger.Error("share WebSocket accept failed", "err", err, "remote", r.RemoteAddr)
+		return
+	}
+	defer func() { _ = conn.CloseNow() }()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	cols, rows := 80, 24
+	readCtx, readCancel := context.WithTimeout(ctx, 5*time.Second)
+	_, data, err := conn.Read(readCtx)
+	readCancel()
+	if err == nil && len(data) > 0 && data[0] == MsgResize {
+		var resize ResizeMessage
+		if err := json.Unmarshal(data[1:], &resize); err == nil {
+			cols, rows = resize.Cols, resize.Rows
+		}
+	}
+
+	sub, replay := entry.hub.attach(entry.role, cols, rows)
+	defer entry.hub.detach(sub)
+
+	logger.Info("viewer attached to shared session", "session", entry.hub.id, "role", entry.role, "remote", r.RemoteAddr)
+
+	if len(replay) > 0 {
+		_ = conn.Write(ctx, websocket.MessageBinary, append([]byte{MsgOutput}, replay...))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case chunk, ok := <-sub.ch:
+				if !ok {
+					_ = conn.Write(ctx, websocket.MessageBinary, []byte{MsgClose})
+					return
+				}
+				if err := conn.Write(ctx, websocket.MessageBinary, append([]byte{MsgOutput}, chunk...)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		for {
+			_, data, err := conn.Read(ctx)
+			if err != nil {
+				return
+			}
+			if len(data) == 0 {
+				continue
+			}
+			switch data[0] {
+			case MsgInput:
+				if entry.role == roleDriver && !s.config.ReadOnly {
+					entry.hub.writeInput(data[1:])
+				}
+			case MsgResize:
+				var resize ResizeMessage
+				if err := json.Unmarshal(data[1:], &resize); err == nil {
+					entry.hub.resize(sub, resize.Cols, resize.Rows)
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+}
@@ -27,6 +27,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea/v2"
 	"github.com/charmbracelet/colorprofile"
 	"github.com/charmbracelet/log"
+	gossh "github.com/charmbracelet/ssh"
 )
 
 // Session represents a web terminal session, similar to ssh.Session in Wish.
@@ -55,6 +56,16 @@ type Session interface {
 
 	// WindowChanges returns a channel that receives window size changes.
 	WindowChanges() <-chan WindowSize
+
+	// Share enables multi-viewer access to this session, returning a
+	// privileged writer URL and a read-only viewer URL that other browsers
+	// can open to observe (and, for the writer URL, drive) the same PTY.
+	// It requires Config.EnableSharing; otherwise both URLs are empty.
+	Share() (writerURL, readerURL string)
+
+	// Files returns the file-transfer channel for this session, letting
+	// the handler send and receive files alongside the terminal stream.
+	Files() FileChannel
 }
 
 // Pty represents pseudo-terminal information.
@@ -107,6 +118,126 @@ type Config struct {
 
 	// Debug enables verbose logging
 	Debug bool
+
+	// EnableSharing turns on the Session.Share() API, letting a session be
+	// observed (and optionally driven) by multiple concurrent browsers.
+	EnableSharing bool
+
+	// SharePathPrefix is the URL path prefix shared session URLs are mounted
+	// under when EnableSharing is true (default: "/share/").
+	SharePathPrefix string
+
+	// SSH configures the optional SSH front-end started by Server.ServeSSH.
+	SSH SSHConfig
+
+	// RecordDir, when set, enables recording of every session's terminal
+	// stream to an asciinema asciicast v2 file in this directory.
+	RecordDir string
+
+	// RecordInput also records client keystrokes as "i" events. Disabled
+	// by default, matching asciinema's own output-only recording.
+	RecordInput bool
+
+	// RecordFilter, if set, is consulted for each new session to decide
+	// whether it should be recorded. A nil filter records every session.
+	RecordFilter func(Session) bool
+
+	// FileTransfer configures the optional in-band file-transfer channel
+	// exposed via Session.Files().
+	FileTransfer FileTransferConfig
+
+	// DetachTimeout keeps a webSession's PTY and program alive for this
+	// long after its browser disconnects, so a reconnect with the same
+	// reattach cookie resumes the same session instead of starting a new
+	// one. The default, 0, preserves the legacy behavior of tearing the
+	// session down immediately on disconnect.
+	DetachTimeout time.Duration
+
+	// Relay configures the optional yamux relay tunnel started by
+	// Server.ServeRelay, which exposes the terminal publicly through a
+	// relay server instead of a directly reachable listener.
+	Relay RelayConfig
+
+	// EnableNamedSessions turns on /ws?session=<name> collaborative
+	// joining: the first connection to request a name creates the
+	// session, and later connections for the same name attach to it
+	// instead of each getting their own PTY, as spectators unless they
+	// present the session's driver token while no driver is attached.
+	EnableNamedSessions bool
+
+	// MaxViewersPerSession caps how many connections may attach to a
+	// single named session at once (0 = unlimited). Only enforced when
+	// EnableNamedSessions is set.
+	MaxViewersPerSession int
+
+	// Authenticator, if set, gates handleIndex, handleStatic, the
+	// WebSocket/WebTransport endpoints, /cert-hash, and /wt-ticket. A nil
+	// Authenticator (the default) leaves every route open, matching the
+	// legacy behavior.
+	Authenticator Authenticator
+
+	// ResumeGrace keeps a webSession parked for this long after its
+	// browser disconnects so a reconnect that presents the session's id
+	// and resume token (via an initial ResumeMessage, wire protocol v2)
+	// can rebind to it and backfill only the output it missed, tracked by
+	// sequence number. Unlike DetachTimeout's reattach cookie, the token
+	// travels explicitly over the wire, so it also works for WebTransport
+	// clients that can't rely on Set-Cookie. The default, 0, disables
+	// resumption. A background sweeper reaps sessions whose grace period
+	// has elapsed.
+	ResumeGrace time.Duration
+
+	// MetricsEnabled turns on a /metrics endpoint exposing connection,
+	// session and byte-transfer counters in Prometheus text exposition
+	// format, gated by Authenticator like every other route.
+	MetricsEnabled bool
+
+	// MetricsPath overrides the path /metrics is served under. Empty
+	// uses "/metrics". Ignored unless MetricsEnabled is set.
+	MetricsPath string
+}
+
+// FileTransferConfig configures Session.Files(). Uploads routed to the
+// built-in default handler (used when no OnUpload handler is registered)
+// land in a per-session temp directory, gated by AllowedPaths.
+type FileTransferConfig struct {
+	// MaxUploadSize rejects uploads larger than this many bytes (0 = no limit).
+	MaxUploadSize int64
+
+	// AllowedPaths is a set of filepath.Match glob patterns an uploaded
+	// file's name must match. Empty allows any relative, non-escaping name.
+	AllowedPaths []string
+
+	// Disabled turns off the file-transfer channel entirely.
+	Disabled bool
+}
+
+// SSHConfig configures the SSH front-end so the same Handler can be
+// exposed over SSH in addition to (or instead of) HTTPS.
+type SSHConfig struct {
+	// AuthorizedKeysPath, if set, restricts access to the public keys
+	// listed in this file (authorized_keys format). Empty allows any key.
+	AuthorizedKeysPath string
+
+	// PasswordCallback, if set, authenticates connections by password.
+	// It may be used together with AuthorizedKeysPath.
+	PasswordCallback func(ctx gossh.Context, password string) bool
+}
+
+// RelayConfig configures Server.ServeRelay, which tunnels this server's
+// terminal traffic through a remote relay rather than listening directly.
+type RelayConfig struct {
+	// Address is the relay's host:port to dial over TLS, e.g.
+	// "relay.example.com:443".
+	Address string
+
+	// AuthToken identifies and authenticates this process to the relay.
+	AuthToken string
+
+	// Slug is the subdomain/slug this server would like the relay to
+	// publish it under. The relay may reject or rewrite it; the requested
+	// value is only a hint.
+	Slug string
 }
 
 // DefaultConfig returns sensible default configuration.
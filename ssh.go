@@ -0,0 +1,314 @@
+package sip
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea/v2"
+	gossh "github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// ServeSSH exposes handler over SSH using the same Handler contract as
+// Serve, so a Bubble Tea app can be offered over HTTPS and SSH from one
+// app definition. Each incoming SSH session gets its own local PTY (like
+// webSession), bridged to the SSH channel; handler is called with a
+// Session backed by that PTY so MakeOptions works unchanged. ServeSSH
+// blocks until ctx is cancelled.
+func (s *Server) ServeSSH(ctx context.Context, addr string, hostKeyPath string, handler Handler) error {
+	return s.serveSSHWithProgram(ctx, addr, hostKeyPath, newDefaultProgramHandler(handler))
+}
+
+func (s *Server) serveSSHWithProgram(ctx context.Context, addr string, hostKeyPath string, progHandler ProgramHandler) error {
+	opts := []gossh.Option{
+		wish.WithAddress(addr),
+		wish.WithHostKeyPath(hostKeyPath),
+		wish.WithMiddleware(s.sshMiddleware(progHandler)),
+	}
+	if s.config.SSH.AuthorizedKeysPath != "" {
+		opts = append(opts, wish.WithAuthorizedKeys(s.config.SSH.AuthorizedKeysPath))
+	}
+	if s.config.SSH.PasswordCallback != nil {
+		opts = append(opts, wish.WithPasswordAuth(s.config.SSH.PasswordCallback))
+	}
+
+	server, err := wish.NewServer(opts...)
+	if err != nil {
+		return fmt.Errorf("failed to create SSH server: %w", err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		logger.Info("SSH server starting", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && err != gossh.ErrServerClosed {
+			errChan <- fmt.Errorf("SSH server error: %w", err)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		logger.Info("shutting down SSH server")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	case err := <-errChan:
+		return err
+	}
+}
+
+// sshMiddleware adapts each SSH session into a Session and hands it to
+// progHandler, mirroring createSession's in-process Bubble Tea lifecycle.
+func (s *Server) sshMiddleware(progHandler ProgramHandler) wish.Middleware {
+	return func(next gossh.Handler) gossh.Handler {
+		return func(sshSess gossh.Session) {
+			pty, winCh, isPTY := sshSess.Pty()
+			if !isPTY {
+				_, _ = io.WriteString(sshSess, "sip: this application requires a PTY\n")
+				_ = sshSess.Exit(1)
+				return
+			}
+
+			sess, err := newSSHSession(sshSess, s.server, s.config, pty.Window.Width, pty.Window.Height)
+			if err != nil {
+				logger.Error("ssh session creation failed", "err", err, "remote", sshSess.RemoteAddr())
+				_ = sshSess.Exit(1)
+				return
+			}
+			defer sess.close()
+
+			logger.Info("SSH session started",
+				"remote", sshSess.RemoteAddr(),
+				"cols", sess.cols,
+				"rows", sess.rows,
+			)
+
+			go sess.bridge()
+			go sess.watchResize(winCh)
+
+			program := progHandler(sess)
+			if program == nil {
+				return
+			}
+			sess.program = program
+
+			if _, err := program.Run(); err != nil {
+				logger.Error("ssh program error", "session", sess.id, "error", err)
+			}
+		}
+	}
+}
+
+// sshSession implements Session for an SSH connection by bridging the
+// wish/ssh channel to a locally-created PTY (the same platformPty used by
+// webSession), so MakeOptions can hand Bubble Tea a real *os.File slave.
+//
+// This isn't a shortcut: charmbracelet/ssh's Session is a network channel
+// with no underlying file descriptor of its own (its Pty() only reports the
+// client's requested Window and terminal Modes, and it explicitly does not
+// implement terminal modes itself - see its NewPtyWriter doc). Bubble Tea
+// requires a real *os.File to put in raw mode, so a local PTY is the only
+// way to give it one; this session's Fd() and PtySlave() are necessarily
+// the local PTY's, not the SSH channel's.
+type sshSession struct {
+	id            string
+	srv           *httpServer
+	sshSess       gossh.Session
+	program       *tea.Program
+	platform      *platformPty
+	cols, rows    int
+	ctx           context.Context
+	cancelFunc    context.CancelFunc
+	mu            sync.Mutex
+	windowChanges chan WindowSize
+	shareOnce     sync.Once
+	shareWriter   string
+	shareReader   string
+	fileCfg       FileTransferConfig
+	filesOnce     sync.Once
+	files         *fileChannel
+	hubOnce       sync.Once
+	hub           *shareHub
+}
+
+func newSSHSession(sshSess gossh.Session, srv *httpServer, config Config, cols, rows int) (*sshSession, error) {
+	if cols <= 0 {
+		cols = 80
+	}
+	if rows <= 0 {
+		rows = 24
+	}
+
+	platform, err := newPlatformPty(cols, rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create PTY: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(sshSess.Context())
+
+	return &sshSession{
+		id:            fmt.Sprintf("%d", time.Now().UnixNano()),
+		srv:           srv,
+		sshSess:       sshSess,
+		platform:      platform,
+		cols:          cols,
+		rows:          rows,
+		ctx:           ctx,
+		cancelFunc:    cancel,
+		windowChanges: make(chan WindowSize, 1),
+		fileCfg:       config.FileTransfer,
+	}, nil
+}
+
+// bridge copies bytes between the SSH channel and the local PTY master in
+// both directions until either side closes. When EnableSharing is set, the
+// PTY master is read through the hub instead of directly: Share() can be
+// called at any point and would otherwise become a second concurrent reader
+// of the same PTY, corrupting the output both it and this channel see.
+func (s *sshSession) bridge() {
+	go func() {
+		_, _ = io.Copy(s.platform.InputWriter(), s.sshSess)
+	}()
+
+	if s.srv != nil && s.srv.config.EnableSharing {
+		hub := s.ensureHub()
+		sub, replay := hub.attach(roleDriver, s.cols, s.rows)
+		defer hub.detach(sub)
+		if len(replay) > 0 {
+			_, _ = s.sshSess.Write(replay)
+		}
+		for chunk := range sub.ch {
+			if _, err := s.sshSess.Write(chunk); err != nil {
+				break
+			}
+		}
+	} else {
+		_, _ = io.Copy(s.sshSess, s.platform.OutputReader())
+	}
+	s.cancelFunc()
+}
+
+// watchResize forwards wish's PTY window-change channel into Resize.
+func (s *sshSession) watchResize(winCh <-chan gossh.Window) {
+	for {
+		select {
+		case win, ok := <-winCh:
+			if !ok {
+				return
+			}
+			s.Resize(win.Width, win.Height)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *sshSession) close() {
+	s.cancelFunc()
+	if s.program != nil {
+		s.program.Quit()
+	}
+	if s.platform != nil {
+		_ = s.platform.Close()
+	}
+}
+
+func (s *sshSession) Pty() Pty {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Pty{Width: s.cols, Height: s.rows}
+}
+
+func (s *sshSession) Context() context.Context {
+	return s.ctx
+}
+
+func (s *sshSession) Read(p []byte) (n int, err error) {
+	return s.platform.SlaveReader().Read(p)
+}
+
+func (s *sshSession) Write(p []byte) (n int, err error) {
+	return s.platform.SlaveWriter().Write(p)
+}
+
+func (s *sshSession) Fd() uintptr {
+	return s.platform.SlaveFd()
+}
+
+func (s *sshSession) PtySlave() *os.File {
+	return s.platform.SlaveFile()
+}
+
+func (s *sshSession) WindowChanges() <-chan WindowSize {
+	return s.windowChanges
+}
+
+func (s *sshSession) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+func (s *sshSession) Resize(cols, rows int) {
+	s.mu.Lock()
+	s.cols = cols
+	s.rows = rows
+	s.mu.Unlock()
+
+	if s.platform != nil {
+		_ = s.platform.Resize(cols, rows)
+	}
+
+	select {
+	case s.windowChanges <- WindowSize{Width: cols, Height: rows}:
+	default:
+	}
+
+	if s.program != nil {
+		s.program.Send(tea.WindowSizeMsg{Width: cols, Height: rows})
+	}
+}
+
+// OutputReader returns the reader for terminal output (for the share hub).
+func (s *sshSession) OutputReader() io.Reader {
+	return s.platform.OutputReader()
+}
+
+// InputWriter returns the writer for terminal input (for the share hub).
+func (s *sshSession) InputWriter() io.Writer {
+	return s.platform.InputWriter()
+}
+
+// Share enables multi-viewer access to this session. See the Session
+// interface documentation for details.
+func (s *sshSession) Share() (writerURL, readerURL string) {
+	if s.srv == nil || !s.srv.config.EnableSharing {
+		return "", ""
+	}
+	s.shareOnce.Do(func() {
+		s.shareWriter, s.shareReader = s.srv.share(s.id, s.ensureHub())
+	})
+	return s.shareWriter, s.shareReader
+}
+
+// ensureHub lazily creates and starts the fan-out hub this session's PTY
+// output is read through.
+func (s *sshSession) ensureHub() *shareHub {
+	s.hubOnce.Do(func() {
+		s.hub = newShareHub(s.id, s)
+		s.hub.start()
+	})
+	return s.hub
+}
+
+// Files returns the file-transfer channel for this session. Note that SSH
+// sessions carry a raw terminal byte stream with no frame multiplexing, so
+// the returned channel can register an OnUpload handler but never receives
+// uploads, and Download always fails with "no client attached".
+func (s *sshSession) Files() FileChannel {
+	s.filesOnce.Do(func() {
+		s.files = newFileChannel(s.id, s.fileCfg)
+	})
+	return s.files
+}